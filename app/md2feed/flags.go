@@ -0,0 +1,79 @@
+package md2feed
+
+import (
+	"flag"
+
+	"github.com/whosonfirst/go-whosonfirst-markdown/flags"
+)
+
+var mode string
+var opml string
+var input string
+var output string
+var format string
+var feed_url string
+var site_title string
+var site_description string
+var site_url string
+var site_author string
+var site_image string
+var site_id string
+var items int
+var full_content bool
+var podcast bool
+var podcast_category string
+var validate bool
+var georss bool
+var drafts bool
+var future bool
+var build_time string
+var authors_data string
+var taxonomies string
+var cache_path string
+var watch_changes bool
+var places_data string
+var templates flags.FeedTemplateFlags
+var writers flags.WriterFlags
+
+// DefaultFlagSet returns a new flag.FlagSet with all the flags for wof-md2feed.
+
+func DefaultFlagSet() *flag.FlagSet {
+
+	fs := flag.NewFlagSet("wof-md2feed", flag.ExitOnError)
+
+	// multi-value flags are appended to so start from scratch every time
+
+	templates = nil
+	writers = nil
+
+	fs.StringVar(&mode, "mode", "all", "Valid modes are: all, authors, category, tags. The all mode produces a single feed; the others produce a feed for each author, category or tag in the same directory structure as wof-md2idx")
+	fs.StringVar(&opml, "opml", "feeds.opml", "The filename of the OPML file listing all the feeds produced by the authors, category and tags modes. If empty no OPML file is written")
+	fs.StringVar(&input, "input", "index.md", "What you expect the input Markdown file to be called")
+	fs.StringVar(&output, "output", "", "The filename of your feed. If empty default to the value of -format + \".xml\" (or \".json\" for JSON Feeds)")
+	fs.StringVar(&format, "format", "rss_20", "Valid options are: atom_10, geojson, jsonfeed_11, rss_20")
+	fs.StringVar(&feed_url, "feed-url", "", "The URL of the feed itself, used for self links. If relative it is resolved against -site-url")
+	fs.StringVar(&site_title, "site-title", "", "The title of your site. If empty defaults to the host of -site-url")
+	fs.StringVar(&site_description, "site-description", "", "A description of your site")
+	fs.StringVar(&site_url, "site-url", "", "The base URL of your site, used to make permalinks absolute")
+	fs.StringVar(&site_author, "site-author", "", "The default author for your site, used when a post has no authors")
+	fs.StringVar(&site_image, "site-image", "", "The URL of an image for your site. If relative it is resolved against -site-url")
+	fs.StringVar(&site_id, "site-id", "", "A unique identifier for your site's feeds. If empty defaults to the value of -site-url")
+	fs.IntVar(&items, "items", 10, "The number of items to include in your feed. If 0 then all items are included. GeoJSON feeds always include every (geotagged) post")
+	fs.BoolVar(&full_content, "full-content", false, "Include the rendered HTML body of each post in the feed. Relative links and images are made absolute using -site-url")
+	fs.BoolVar(&podcast, "podcast", false, "Include iTunes podcast elements in RSS feeds")
+	fs.StringVar(&podcast_category, "podcast-category", "", "The iTunes category for your podcast feed")
+	fs.BoolVar(&validate, "validate", false, "Validate each feed (RSS, Atom and JSON Feed only) before writing it. Invalid feeds are not written and cause wof-md2feed to fail")
+	fs.BoolVar(&georss, "georss", false, "Include GeoRSS elements for posts with coordinates")
+	fs.BoolVar(&drafts, "drafts", false, "Include drafts (posts with \"published: false\" front matter or in a drafts directory)")
+	fs.BoolVar(&future, "future", false, "Include posts dated after the build time")
+	fs.StringVar(&build_time, "build-time", "", "The time (an RFC3339 timestamp or a YYYY-MM-DD date) that posts are considered published relative to. If empty the current time is used")
+	fs.StringVar(&authors_data, "authors-data", "", "The path to a directory containing author profiles (JSON or Markdown files named for each author) used to add names, URLs, email addresses and avatars to feed authors")
+	fs.StringVar(&taxonomies, "taxonomy", "", "The path to a JSON file defining aliases, canonical names and slugs for authors, categories and tags. It should be the same file passed to wof-md2idx")
+	fs.StringVar(&cache_path, "cache", "", "The path to a build manifest used to skip feeds whose posts haven't changed since the last build and to remove feeds that no longer have any posts. If empty every feed is written")
+	fs.BoolVar(&watch_changes, "watch", false, "After writing feeds, watch the directories being syndicated, templates, the taxonomy and author profiles for changes and rewrite the feeds affected by them")
+	fs.StringVar(&places_data, "places-data", "", "The path to a local Who's On First data directory used to derive coordinates for posts that reference places but do not have coordinates of their own")
+	fs.Var(&templates, "templates", "One or more directories containing (Go) templates to parse")
+	fs.Var(&writers, "writer", "One or more writer to output rendered Markdown to. Valid writers are: fs=PATH; null; stdout")
+
+	return fs
+}
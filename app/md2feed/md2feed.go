@@ -0,0 +1,745 @@
+package md2feed
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/whosonfirst/go-whosonfirst-markdown"
+	"github.com/whosonfirst/go-whosonfirst-markdown/authors"
+	"github.com/whosonfirst/go-whosonfirst-markdown/cache"
+	"github.com/whosonfirst/go-whosonfirst-markdown/jekyll"
+	"github.com/whosonfirst/go-whosonfirst-markdown/places"
+	"github.com/whosonfirst/go-whosonfirst-markdown/publish"
+	"github.com/whosonfirst/go-whosonfirst-markdown/render"
+	"github.com/whosonfirst/go-whosonfirst-markdown/site"
+	"github.com/whosonfirst/go-whosonfirst-markdown/taxonomy"
+	"github.com/whosonfirst/go-whosonfirst-markdown/uri"
+	"github.com/whosonfirst/go-whosonfirst-markdown/watch"
+	"github.com/whosonfirst/go-whosonfirst-markdown/writer"
+)
+
+type nopCloser struct {
+	io.Reader
+}
+
+func (nopCloser) Close() error { return nil }
+
+func Render(ctx context.Context, path string, opts *render.FeedOptions) error {
+
+	if ctx.Err() != nil {
+		return errors.New("Interrupted, not every feed was written")
+	}
+
+	err := RenderDirectory(ctx, path, opts)
+
+	if err != nil {
+		return err
+	}
+
+	// keys that were never reached look just like keys that no longer have any
+	// posts so only prune after a complete build (see cache.Manifest.Prune)
+
+	if ctx.Err() != nil {
+		return errors.New("Interrupted, not every feed was written")
+	}
+
+	// remove the feeds for tags (authors, etc.) that no longer have any posts
+
+	m, _ := ctx.Value("cache").(*cache.Manifest)
+
+	pruned, err := m.Prune(ctx, cacheKey(path, opts))
+
+	if err != nil {
+		return err
+	}
+
+	for _, k := range pruned {
+		log.Printf("Removed feed for %s\n", k)
+	}
+
+	return nil
+}
+
+// cacheKey returns the key in the build manifest for the feed written to root.
+// Keys are prefixed by the mode and the name of the feed so that, for example,
+// RSS and Atom feeds can share a manifest.
+
+func cacheKey(root string, opts *render.FeedOptions) string {
+
+	abs_root, err := filepath.Abs(root)
+
+	if err == nil {
+		root = abs_root
+	}
+
+	return fmt.Sprintf("feed:%s:%s:%s%c", opts.Mode, opts.Output, root, filepath.Separator)
+}
+
+func RenderDirectory(ctx context.Context, dir string, opts *render.FeedOptions) error {
+
+	abs_dir, err := filepath.Abs(dir)
+
+	if err != nil {
+		return err
+	}
+
+	// site-absolute enclosures are relative to dir
+
+	dir_site := *opts.Site
+	dir_site.Root = abs_dir
+
+	dir_opts := *opts
+	dir_opts.Site = &dir_site
+
+	opts = &dir_opts
+
+	posts, err := GatherPosts(ctx, dir, opts)
+
+	if err != nil {
+		return err
+	}
+
+	if len(posts) == 0 {
+		return nil
+	}
+
+	if opts.Places != nil {
+
+		for _, doc := range posts {
+			doc.FrontMatter.Coordinates = opts.Places.Coordinates(doc.FrontMatter)
+		}
+	}
+
+	switch opts.Mode {
+	case "all":
+		return RenderPosts(ctx, dir, LimitPosts(posts, opts), opts)
+	case "authors", "category", "tags":
+		return RenderGroups(ctx, dir, posts, opts)
+	default:
+		return errors.New("Invalid or unsupported mode")
+	}
+}
+
+// RenderGroups writes a feed for each author, category or tag (depending on opts.Mode)
+// using the same directory structure as wof-md2idx (for example tags/{TAG}/rss_20.xml)
+// and an OPML file listing all of those feeds.
+
+func RenderGroups(ctx context.Context, dir string, posts []*markdown.Document, opts *render.FeedOptions) error {
+
+	// see also: wof-md2idx
+
+	t := opts.Taxonomies.Taxonomy(opts.Mode)
+
+	key := func(doc *markdown.Document) ([]string, error) {
+
+		var values []string
+
+		switch opts.Mode {
+		case "authors":
+			values = doc.FrontMatter.Authors
+		case "category":
+			values = uri.PathAncestors(t.Canonical(doc.FrontMatter.Category))
+		case "tags":
+			values = doc.FrontMatter.Tags
+		default:
+			return nil, errors.New("Invalid or unsupported mode")
+		}
+
+		keys := make([]string, len(values))
+
+		for i, v := range values {
+			keys[i] = t.Canonical(strings.TrimSpace(v))
+		}
+
+		return keys, nil
+	}
+
+	groups, err := site.GroupDocuments(posts, key)
+
+	if err != nil {
+		return err
+	}
+
+	keys := groups.Keys()
+
+	// categories may be hierarchical (for example "engineering/data")
+
+	slug := t.Slug
+
+	if opts.Mode == "category" {
+		slug = t.SlugPath
+	}
+
+	err = taxonomy.CheckSlugs(keys, slug)
+
+	if err != nil {
+		return err
+	}
+
+	root := filepath.Join(dir, opts.Mode)
+	outlines := make([]*render.OPMLOutline, 0)
+
+	for _, raw := range keys {
+
+		clean, err := slug(raw)
+
+		if err != nil {
+			return err
+		}
+
+		if clean == "" {
+			continue
+		}
+
+		k_dir := filepath.Join(root, filepath.FromSlash(clean))
+
+		// paths relative to the directory containing the OPML file
+
+		rel_feed := path.Join(clean, opts.Output)
+		rel_page := clean + "/"
+
+		k_site := *opts.Site
+		k_site.Title = raw
+
+		if opts.Site.Title != "" {
+			k_site.Title = fmt.Sprintf("%s - %s", opts.Site.Title, raw)
+		}
+
+		k_opts := *opts
+		k_opts.Site = &k_site
+		k_opts.URL = ""
+
+		if opts.URL != "" {
+			k_opts.URL = resolveURL(opts.URL, path.Join(opts.Mode, rel_feed))
+			rel_feed = opts.Site.AbsoluteURL(k_opts.URL)
+			rel_page = opts.Site.AbsoluteURL(resolveURL(opts.URL, path.Join(opts.Mode, clean)+"/"))
+		}
+
+		err = RenderPosts(ctx, k_dir, LimitPosts(groups.Documents(raw), opts), &k_opts)
+
+		if err != nil {
+			return err
+		}
+
+		o := render.OPMLOutline{
+			Type:    render.OPMLType(opts.Format),
+			Text:    raw,
+			Title:   k_site.Title,
+			XMLURL:  rel_feed,
+			HTMLURL: rel_page,
+		}
+
+		outlines = append(outlines, &o)
+	}
+
+	if opts.OPML == "" {
+		return nil
+	}
+
+	title := opts.Mode
+
+	if opts.Site.Title != "" {
+		title = fmt.Sprintf("%s - %s", opts.Site.Title, opts.Mode)
+	}
+
+	fh, err := render.RenderOPML(title, outlines)
+
+	if err != nil {
+		return err
+	}
+
+	w := ctx.Value("writer").(writer.Writer)
+
+	if w == nil {
+		return errors.New("Can't load writer from context")
+	}
+
+	out_path := filepath.Join(root, opts.OPML)
+	return w.Write(out_path, fh)
+}
+
+// LimitPosts returns (at most) the first opts.Items posts. If opts.Items is 0 then
+// all the posts are returned, as they are for GeoJSON which is a map of every post
+// rather than a list of recent ones.
+
+func LimitPosts(posts []*markdown.Document, opts *render.FeedOptions) []*markdown.Document {
+
+	if opts.Format == "geojson" {
+		return posts
+	}
+
+	if opts.Items <= 0 || len(posts) <= opts.Items {
+		return posts
+	}
+
+	return posts[0:opts.Items]
+}
+
+func resolveURL(base string, rel string) string {
+
+	base_u, err := url.Parse(base)
+
+	if err != nil {
+		return rel
+	}
+
+	rel_u, err := url.Parse(rel)
+
+	if err != nil {
+		return rel
+	}
+
+	return base_u.ResolveReference(rel_u).String()
+}
+
+// GatherPosts returns every published post in root. Post bodies are only parsed
+// if opts.FullContent is true.
+
+func GatherPosts(ctx context.Context, root string, opts *render.FeedOptions) ([]*markdown.Document, error) {
+
+	site_opts := site.DefaultSiteOptions()
+	site_opts.Input = opts.Input
+	site_opts.Body = opts.FullContent
+	site_opts.Filter = opts.Filter
+
+	s, err := site.LoadSite(ctx, root, site_opts)
+
+	if err != nil {
+		return nil, err
+	}
+
+	// see jekyll.ComparePosts for details on how posts are ordered
+
+	return s.Documents(), nil
+}
+
+// RenderPosts writes a feed for posts to root, unless the build manifest (if
+// there is one) says that the posts haven't changed since the last build.
+
+func RenderPosts(ctx context.Context, root string, posts []*markdown.Document, opts *render.FeedOptions) error {
+
+	m, _ := ctx.Value("cache").(*cache.Manifest)
+
+	var source string
+
+	if m != nil {
+
+		bodies := make([]string, len(posts))
+
+		// posts may have bodies even if they aren't used, see site.LoadSite
+
+		for i, doc := range posts {
+
+			if opts.FullContent && doc.Body != nil {
+				bodies[i] = doc.Body.String()
+			}
+		}
+
+		h, err := cache.HashJSON(opts.Site, site.FrontMatter(posts), bodies)
+
+		if err != nil {
+			return err
+		}
+
+		source = h
+	}
+
+	return m.Build(ctx, cacheKey(root, opts), source, func(ctx context.Context) error {
+		return renderPosts(ctx, root, posts, opts)
+	})
+}
+
+// FeedItem is a post as passed to custom feed templates (as .Items): its front
+// matter, its enclosures and, if opts.FullContent is true, its body rendered as
+// HTML with absolute URLs (see render.RenderContent).
+
+type FeedItem struct {
+	Post       *jekyll.FrontMatter
+	Content    string
+	Enclosures []*render.FeedEnclosure
+}
+
+func NewFeedItem(doc *markdown.Document, opts *render.FeedOptions) (*FeedItem, error) {
+
+	item := FeedItem{
+		Post:       doc.FrontMatter,
+		Enclosures: render.FeedEnclosures(doc, opts.Site),
+	}
+
+	if opts.FullContent {
+
+		content, err := render.RenderContent(doc, opts.Site)
+
+		if err != nil {
+			return nil, err
+		}
+
+		item.Content = content
+	}
+
+	return &item, nil
+}
+
+func renderPosts(ctx context.Context, root string, posts []*markdown.Document, opts *render.FeedOptions) error {
+
+	select {
+	case <-ctx.Done():
+		return nil
+	default:
+
+		w := ctx.Value("writer").(writer.Writer)
+
+		if w == nil {
+			return errors.New("Can't load writer from context")
+		}
+
+		out_path := filepath.Join(root, opts.Output)
+
+		if opts.Format == "geojson" {
+
+			fh, err := render.RenderGeoJSON(posts, opts)
+
+			if err != nil {
+				return err
+			}
+
+			return w.Write(out_path, fh)
+		}
+
+		// custom templates, if present, take precedence over the built-in
+		// feed encoders
+
+		var t *template.Template
+
+		if opts.Templates != nil {
+			t_name := fmt.Sprintf("feed_%s", opts.Format)
+			t = opts.Templates.Lookup(t_name)
+		}
+
+		if t == nil {
+
+			fh, err := render.RenderFeed(posts, opts)
+
+			if err != nil {
+				return err
+			}
+
+			return WriteFeed(w, out_path, fh, opts)
+		}
+
+		type Data struct {
+			Posts           []*jekyll.FrontMatter
+			Items           []*FeedItem
+			BuildDate       time.Time
+			Site            *render.SiteMetadata
+			GeoRSS          bool
+			GeoRSSNamespace string
+		}
+
+		now := time.Now()
+
+		fm_posts := make([]*jekyll.FrontMatter, len(posts))
+		items := make([]*FeedItem, len(posts))
+
+		for idx, doc := range posts {
+
+			item, err := NewFeedItem(doc, opts)
+
+			if err != nil {
+				return err
+			}
+
+			fm_posts[idx] = doc.FrontMatter
+			items[idx] = item
+		}
+
+		d := Data{
+			Posts:           fm_posts,
+			Items:           items,
+			BuildDate:       now,
+			Site:            opts.Site,
+			GeoRSS:          opts.GeoRSS,
+			GeoRSSNamespace: render.GeoRSSNamespace,
+		}
+
+		// PLEASE REPLACE ALL OF THIS WILL A GENERIC utils.WriteTemplate
+		// THAT WRAPS ALL THE atomicfile STUFF AND WRITES STRAIGHT TO A
+		// FILEHANDLE RATHER THAN BYTES THEN... (20180130/thisisaaronland)
+
+		var b bytes.Buffer
+		wr := bufio.NewWriter(&b)
+
+		err := t.Execute(wr, d)
+
+		if err != nil {
+			return err
+		}
+
+		wr.Flush()
+
+		r := bytes.NewReader(b.Bytes())
+		fh := nopCloser{r}
+
+		return WriteFeed(w, out_path, fh, opts)
+	}
+}
+
+// WriteFeed writes fh to out_path, first validating it if opts.Validate is true.
+// Invalid feeds are not written.
+
+func WriteFeed(w writer.Writer, out_path string, fh io.ReadCloser, opts *render.FeedOptions) error {
+
+	if !opts.Validate {
+		return w.Write(out_path, fh)
+	}
+
+	body, err := ioutil.ReadAll(fh)
+
+	if err != nil {
+		return err
+	}
+
+	v, err := render.ValidateFeed(bytes.NewReader(body))
+
+	if err != nil {
+		return err
+	}
+
+	for _, warning := range v.Warnings {
+		log.Printf("%s: warning: %s\n", out_path, warning)
+	}
+
+	if !v.Valid() {
+
+		for _, p := range v.Problems {
+			log.Printf("%s: %s\n", out_path, p)
+		}
+
+		return errors.New(fmt.Sprintf("Feed %s failed validation with %d problem(s)", out_path, len(v.Problems)))
+	}
+
+	return w.Write(out_path, nopCloser{bytes.NewReader(body)})
+}
+
+// Run parses the command line and runs wof-md2feed.
+
+func Run(ctx context.Context) error {
+
+	fs := DefaultFlagSet()
+	fs.Parse(os.Args[1:])
+
+	return RunWithFlagSet(ctx, fs)
+}
+
+// RunWithFlagSet runs wof-md2feed with the (already parsed) flags and arguments in fs.
+
+func RunWithFlagSet(ctx context.Context, fs *flag.FlagSet) error {
+
+	wr, err := writers.ToWriterWithContext(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	if output == "" {
+		output = fmt.Sprintf("%s.%s", format, render.FeedExtension(format))
+	}
+
+	opts := render.DefaultFeedOptions()
+	opts.Mode = mode
+	opts.OPML = opml
+	opts.Input = input
+	opts.Output = output
+	opts.Format = format
+	opts.Items = items
+	opts.URL = feed_url
+	opts.FullContent = full_content
+	opts.GeoRSS = georss
+	opts.Validate = validate
+	opts.Podcast = podcast
+	opts.PodcastCategory = podcast_category
+
+	filter, err := publish.NewFilterWithContext(ctx, drafts, future, build_time)
+
+	if err != nil {
+		return err
+	}
+
+	opts.Filter = filter
+
+	// RSS, Atom and JSON Feed all require a title so if there isn't one use
+	// the host that the site is served from
+
+	if site_title == "" && site_url != "" {
+
+		u, err := url.Parse(site_url)
+
+		if err != nil {
+			return err
+		}
+
+		site_title = u.Host
+	}
+
+	opts.Site.Title = site_title
+	opts.Site.Description = site_description
+	opts.Site.BaseURL = site_url
+	opts.Site.Author = site_author
+	opts.Site.Image = site_image
+	opts.Site.Id = site_id
+
+	if places_data != "" {
+
+		r, err := places.NewLocalResolver(places_data)
+
+		if err != nil {
+			return err
+		}
+
+		opts.Places = r
+	}
+
+	ctx = context.WithValue(ctx, "writer", wr)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// see notes in wof-md2html about watch mode
+
+	var m *cache.Manifest
+
+	if cache_path != "" {
+
+		m, err = cache.OpenManifest(cache_path, "", "")
+
+		if err != nil {
+			return err
+		}
+
+	} else if watch_changes {
+		m = cache.NewManifest("", "")
+	}
+
+	if m != nil {
+		ctx = context.WithValue(ctx, "cache", m)
+	}
+
+	// build writes every feed once. In watch mode it is called again every
+	// time something changes which is why templates, the taxonomy and author
+	// profiles are loaded here.
+
+	build := func(ctx context.Context) error {
+
+		t, err := templates.Parse()
+
+		if err != nil {
+			return err
+		}
+
+		opts.Templates = t
+		opts.Authors = nil
+		opts.Taxonomies = nil
+
+		if authors_data != "" {
+
+			p, err := authors.NewProfilesFromDirectory(authors_data)
+
+			if err != nil {
+				return err
+			}
+
+			opts.Authors = p
+		}
+
+		if taxonomies != "" {
+
+			t, err := taxonomy.NewTaxonomiesFromFile(taxonomies)
+
+			if err != nil {
+				return err
+			}
+
+			opts.Taxonomies = t
+		}
+
+		if m != nil {
+
+			template_hash, err := cache.HashPaths(templates...)
+
+			if err != nil {
+				return err
+			}
+
+			// as with wof-md2idx the places data directory is not included
+
+			data_hash, err := cache.HashPaths(authors_data, taxonomies)
+
+			if err != nil {
+				return err
+			}
+
+			flags_hash := cache.HashFlags(fs, "cache", "watch")
+			options_hash := cache.HashBytes([]byte(flags_hash), []byte(data_hash))
+
+			m.Reset(template_hash, options_hash)
+		}
+
+		for _, path := range fs.Args() {
+
+			err := Render(ctx, path, opts)
+
+			if err != nil {
+				m.Save()
+				return err
+			}
+		}
+
+		return m.Save()
+	}
+
+	err = build(ctx)
+
+	if !watch_changes {
+		return err
+	}
+
+	if err != nil {
+		log.Println(err)
+	}
+
+	watch_paths := append([]string(templates), fs.Args()...)
+	watch_paths = append(watch_paths, authors_data, taxonomies)
+
+	w, err := watch.NewWatcher(watch_paths, watch.DefaultWatcherOptions())
+
+	if err != nil {
+		return err
+	}
+
+	log.Println("Watching for changes")
+
+	err = w.Watch(ctx, func(ctx context.Context, changes []*watch.Change) error {
+
+		for _, c := range changes {
+			log.Println(c)
+		}
+
+		return build(ctx)
+	})
+
+	return err
+}
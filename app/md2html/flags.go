@@ -0,0 +1,58 @@
+package md2html
+
+import (
+	"flag"
+	"runtime"
+
+	"github.com/whosonfirst/go-whosonfirst-markdown/flags"
+)
+
+var mode string
+var input string
+var output string
+var header string
+var footer string
+var site_root string
+var related int
+var drafts bool
+var future bool
+var build_time string
+var workers int
+var watch_changes bool
+var timings bool
+var cache_path string
+var authors_data string
+var templates flags.HTMLTemplateFlags
+var writers flags.WriterFlags
+
+// DefaultFlagSet returns a new flag.FlagSet with all the flags for wof-md2html.
+
+func DefaultFlagSet() *flag.FlagSet {
+
+	fs := flag.NewFlagSet("wof-md2html", flag.ExitOnError)
+
+	// multi-value flags are appended to so start from scratch every time
+
+	templates = nil
+	writers = nil
+
+	fs.StringVar(&mode, "mode", "files", "Valid modes are: files, directory")
+	fs.StringVar(&input, "input", "index.md", "What you expect the input Markdown file to be called")
+	fs.StringVar(&output, "output", "index.html", "What you expect the output HTML file to be called")
+	fs.StringVar(&header, "header", "", "The name of the (Go) template to use as a custom header")
+	fs.StringVar(&footer, "footer", "", "The name of the (Go) template to use as a custom footer")
+	fs.StringVar(&site_root, "site-root", "", "The root of the site that documents belong to, used to derive previous, next, related and same series posts for header and footer templates. In directory mode this defaults to the directory being rendered")
+	fs.IntVar(&related, "related", 5, "The maximum number of related posts to pass to header and footer templates")
+	fs.BoolVar(&drafts, "drafts", false, "Include drafts (posts with \"published: false\" front matter or in a drafts directory)")
+	fs.BoolVar(&future, "future", false, "Include posts dated after the build time")
+	fs.StringVar(&build_time, "build-time", "", "The time (an RFC3339 timestamp or a YYYY-MM-DD date) that posts are considered published relative to. If empty the current time is used")
+	fs.IntVar(&workers, "workers", runtime.NumCPU(), "The number of files to render concurrently")
+	fs.BoolVar(&watch_changes, "watch", false, "After rendering, watch the files (or directories) being rendered, templates and author profiles for changes and re-render the pages affected by them")
+	fs.BoolVar(&timings, "timings", false, "Report how long each file took to render")
+	fs.StringVar(&cache_path, "cache", "", "The path to a build manifest used to skip files that haven't changed since the last build and to remove the output of files that have been deleted. If empty every file is rendered")
+	fs.StringVar(&authors_data, "authors-data", "", "The path to a directory containing author profiles (JSON or Markdown files named for each author) to pass to header and footer templates")
+	fs.Var(&templates, "templates", "One or more directories containing (Go) templates to parse")
+	fs.Var(&writers, "writer", "One or more writer to output rendered Markdown to. Valid writers are: fs=PATH; null; stdout")
+
+	return fs
+}
@@ -0,0 +1,537 @@
+package md2html
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/whosonfirst/go-whosonfirst-crawl"
+	"github.com/whosonfirst/go-whosonfirst-markdown"
+	"github.com/whosonfirst/go-whosonfirst-markdown/authors"
+	"github.com/whosonfirst/go-whosonfirst-markdown/cache"
+	"github.com/whosonfirst/go-whosonfirst-markdown/nav"
+	"github.com/whosonfirst/go-whosonfirst-markdown/pool"
+	"github.com/whosonfirst/go-whosonfirst-markdown/publish"
+	"github.com/whosonfirst/go-whosonfirst-markdown/render"
+	"github.com/whosonfirst/go-whosonfirst-markdown/site"
+	"github.com/whosonfirst/go-whosonfirst-markdown/watch"
+	"github.com/whosonfirst/go-whosonfirst-markdown/writer"
+)
+
+// RenderDirectory submits every file named opts.Input in dir to p. The crawl stops
+// as soon as ctx, which should be derived from p.Context(), is cancelled.
+
+func RenderDirectory(ctx context.Context, dir string, opts *render.HTMLOptions, p *pool.Pool) error {
+
+	// if the site has already been crawled (and parsed) use that instead
+
+	site_opts := site.DefaultSiteOptions()
+	site_opts.Input = opts.Input
+	site_opts.Body = true
+	site_opts.Filter = opts.Filter
+
+	s := site.SiteFromContext(ctx, dir, site_opts)
+
+	if s != nil {
+
+		for _, doc := range s.Documents() {
+
+			select {
+			case <-ctx.Done():
+				return nil
+			default:
+				// pass
+			}
+
+			doc := doc
+
+			p.Submit(doc.Path, func() error {
+				return RenderDocument(ctx, doc, dir, opts)
+			})
+		}
+
+		return nil
+	}
+
+	cb := func(path string, info os.FileInfo) error {
+
+		if info.IsDir() || filepath.Base(path) != opts.Input {
+			return nil
+		}
+
+		// Submit only fails if the pool has been cancelled in which case
+		// the crawl is stopped and the reason is reported by p.Wait
+
+		p.Submit(path, func() error {
+			return RenderPathWithRoot(ctx, path, dir, opts)
+		})
+
+		return nil
+	}
+
+	c := crawl.NewCrawler(dir)
+	return c.CrawlWithContext(ctx, cb)
+}
+
+func RenderPath(ctx context.Context, path string, opts *render.HTMLOptions, p *pool.Pool) error {
+
+	root := filepath.Dir(path)
+
+	p.Submit(path, func() error {
+		return RenderPathWithRoot(ctx, path, root, opts)
+	})
+
+	return nil
+}
+
+func RenderPathWithRoot(ctx context.Context, path string, root string, opts *render.HTMLOptions) error {
+
+	select {
+
+	case <-ctx.Done():
+		return nil
+	default:
+
+		abs_path, err := filepath.Abs(path)
+
+		if err != nil {
+			return err
+		}
+
+		fname := filepath.Base(abs_path)
+
+		if fname != opts.Input {
+			return nil
+		}
+
+		site_opts := site.DefaultSiteOptions()
+		site_opts.Body = true
+
+		doc, err := site.ParseDocument(abs_path, site_opts)
+
+		if err != nil {
+			return err
+		}
+
+		return RenderDocument(ctx, doc, root, opts)
+	}
+}
+
+// RenderDocument renders doc, which must have been parsed with its body, as the
+// file in root that wof-md2html would have rendered it from.
+
+func RenderDocument(ctx context.Context, doc *markdown.Document, root string, opts *render.HTMLOptions) error {
+
+	select {
+
+	case <-ctx.Done():
+		return nil
+	default:
+
+		abs_path := doc.Path
+		fm := doc.FrontMatter
+
+		// in files mode root is the directory containing the file so check
+		// the entire path for drafts directories
+
+		filter_root := root
+
+		if opts.Mode == "files" {
+			filter_root = ""
+		}
+
+		if !opts.Filter.Include(filter_root, abs_path, fm) {
+			return nil
+		}
+
+		page_ctx := render.NewPageContext(doc, opts)
+
+		n, ok := ctx.Value("navigation").(*nav.Navigation)
+
+		if ok {
+			page_ctx.SetNavigation(n.Context(abs_path))
+		}
+
+		m, _ := ctx.Value("cache").(*cache.Manifest)
+
+		var source string
+
+		if m != nil {
+
+			body, err := os.ReadFile(abs_path)
+
+			if err != nil {
+				return err
+			}
+
+			// pages also depend on the posts around them (see nav.Context)
+
+			source, err = cache.HashJSON(string(body), page_ctx)
+
+			if err != nil {
+				return err
+			}
+		}
+
+		return m.Build(ctx, "html:"+abs_path, source, func(ctx context.Context) error {
+
+			html, err := render.RenderHTMLWithContext(doc, opts, page_ctx)
+
+			if err != nil {
+				return err
+			}
+
+			wr := ctx.Value("writer").(writer.Writer)
+
+			if wr == nil {
+				return errors.New("Can't load writer from context")
+			}
+
+			out_path := render.HTMLOutputPath(fm, abs_path, root, opts)
+			return wr.Write(out_path, html)
+		})
+	}
+}
+
+// Render submits path (or every file in path in directory mode) to p. Errors
+// rendering individual files are reported by p.Wait.
+
+func Render(ctx context.Context, path string, opts *render.HTMLOptions, p *pool.Pool) error {
+
+	select {
+	case <-ctx.Done():
+		return nil
+	default:
+
+		abs_path, err := filepath.Abs(path)
+
+		if err != nil {
+			return err
+		}
+
+		switch opts.Mode {
+
+		case "files":
+			return RenderPath(ctx, abs_path, opts, p)
+		case "directory":
+			return RenderDirectory(ctx, abs_path, opts, p)
+		default:
+			return errors.New("Unknown or invalid mode")
+		}
+	}
+
+}
+
+// Run parses the command line and runs wof-md2html.
+
+func Run(ctx context.Context) error {
+
+	fs := DefaultFlagSet()
+	fs.Parse(os.Args[1:])
+
+	return RunWithFlagSet(ctx, fs)
+}
+
+// RunWithFlagSet runs wof-md2html with the (already parsed) flags and arguments in fs.
+
+func RunWithFlagSet(ctx context.Context, fs *flag.FlagSet) error {
+
+	wr, err := writers.ToWriterWithContext(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	opts := render.DefaultHTMLOptions()
+	opts.Mode = mode
+	opts.Input = input
+	opts.Output = output
+	opts.Header = header
+	opts.Footer = footer
+
+	filter, err := publish.NewFilterWithContext(ctx, drafts, future, build_time)
+
+	if err != nil {
+		return err
+	}
+
+	opts.Filter = filter
+
+	ctx = context.WithValue(ctx, "writer", wr)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// stop rendering (rather than abandoning it half way through a file)
+	// when interrupted
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+
+	go func() {
+
+		select {
+		case <-signals:
+			log.Println("Interrupted, waiting for files in progress to finish")
+			cancel()
+		case <-ctx.Done():
+			// pass
+		}
+	}()
+
+	// in watch mode the manifest is what makes it possible to re-render only
+	// the pages affected by a change so if there isn't one keep it in memory
+
+	var m *cache.Manifest
+
+	if cache_path != "" {
+
+		m, err = cache.OpenManifest(cache_path, "", "")
+
+		if err != nil {
+			return err
+		}
+
+	} else if watch_changes {
+		m = cache.NewManifest("", "")
+	}
+
+	if m != nil {
+		ctx = context.WithValue(ctx, "cache", m)
+	}
+
+	nav_opts := nav.DefaultNavigationOptions()
+	nav_opts.Related = related
+
+	// build renders everything once. In watch mode it is called again every
+	// time something changes which is why templates and author profiles are
+	// loaded here.
+
+	build := func(ctx context.Context) error {
+
+		t1 := time.Now()
+
+		t, err := templates.Parse()
+
+		if err != nil {
+			return err
+		}
+
+		opts.Templates = t
+		opts.Authors = nil
+
+		if authors_data != "" {
+
+			p, err := authors.NewProfilesFromDirectory(authors_data)
+
+			if err != nil {
+				return err
+			}
+
+			opts.Authors = p
+		}
+
+		if m != nil {
+
+			template_hash, err := cache.HashPaths(templates...)
+
+			if err != nil {
+				return err
+			}
+
+			data_hash, err := cache.HashPaths(authors_data)
+
+			if err != nil {
+				return err
+			}
+
+			flags_hash := cache.HashFlags(fs, "cache", "timings", "watch", "workers")
+			options_hash := cache.HashBytes([]byte(flags_hash), []byte(data_hash))
+
+			m.Reset(template_hash, options_hash)
+		}
+
+		pool_opts := pool.DefaultPoolOptions()
+		pool_opts.Workers = workers
+
+		p, err := pool.NewPool(ctx, pool_opts)
+
+		if err != nil {
+			return err
+		}
+
+		// release the pool's context once this build is done, otherwise every
+		// rebuild in watch mode leaves one behind. This happens after the checks
+		// on ctx.Err() below since they are made before returning.
+
+		defer p.Cancel()
+
+		ctx = p.Context()
+
+		navigation := func(root string) (*nav.Navigation, error) {
+
+			site_opts := site.DefaultSiteOptions()
+			site_opts.Input = opts.Input
+			site_opts.Filter = opts.Filter
+
+			s, err := site.LoadSite(ctx, root, site_opts)
+
+			if err != nil {
+				return nil, err
+			}
+
+			return nav.NewNavigation(s.Documents(), nav_opts)
+		}
+
+		if site_root != "" {
+
+			n, err := navigation(site_root)
+
+			if err != nil {
+				return err
+			}
+
+			ctx = context.WithValue(ctx, "navigation", n)
+		}
+
+		for _, path := range fs.Args() {
+
+			path_ctx := ctx
+
+			if site_root == "" && mode == "directory" {
+
+				n, err := navigation(path)
+
+				if err != nil {
+					p.Cancel()
+					p.Wait()
+					return err
+				}
+
+				path_ctx = context.WithValue(ctx, "navigation", n)
+			}
+
+			err := Render(path_ctx, path, opts, p)
+
+			if err != nil {
+				log.Println(err)
+				p.Cancel()
+				break
+			}
+		}
+
+		results, err := p.Wait()
+
+		if timings {
+
+			var total time.Duration
+
+			for _, r := range results {
+				log.Printf("%s %v\n", r.Name, r.Duration)
+				total += r.Duration
+			}
+
+			log.Printf("Rendered %d files in %v (%v with %d workers)\n", len(results), total, time.Since(t1), workers)
+		}
+
+		// only remove the output of files that weren't rendered (or skipped) if
+		// everything else was rendered, otherwise we can't tell deleted files apart
+		// from files that just weren't reached
+
+		if err == nil && ctx.Err() == nil && mode == "directory" {
+
+			for _, path := range fs.Args() {
+
+				abs_path, err := filepath.Abs(path)
+
+				if err != nil {
+					return err
+				}
+
+				pruned, err := m.Prune(ctx, "html:"+abs_path+string(filepath.Separator))
+
+				if err != nil {
+					return err
+				}
+
+				for _, k := range pruned {
+					log.Printf("Removed output for %s\n", strings.TrimPrefix(k, "html:"))
+				}
+			}
+		}
+
+		save_err := m.Save()
+
+		if save_err != nil {
+			return save_err
+		}
+
+		if err != nil {
+
+			failed, ok := err.(pool.Errors)
+
+			if !ok {
+				return err
+			}
+
+			for _, r := range failed {
+				log.Printf("Failed to render %s, %v\n", r.Name, r.Err)
+			}
+
+			return fmt.Errorf("Failed to render %d files", len(failed))
+		}
+
+		// a build that was interrupted (or cancelled by whatever is running
+		// wof-md2html) is not complete even if nothing failed
+
+		if ctx.Err() != nil {
+			return errors.New("Interrupted, not every file was rendered")
+		}
+
+		return nil
+	}
+
+	err = build(ctx)
+
+	if !watch_changes {
+		return err
+	}
+
+	if err != nil {
+		log.Println(err)
+	}
+
+	// new template files are not picked up until wof-md2html is restarted
+	// since the list of templates is determined when flags are parsed
+
+	watch_paths := append([]string(templates), fs.Args()...)
+	watch_paths = append(watch_paths, authors_data, site_root)
+
+	w, err := watch.NewWatcher(watch_paths, watch.DefaultWatcherOptions())
+
+	if err != nil {
+		return err
+	}
+
+	log.Println("Watching for changes")
+
+	err = w.Watch(ctx, func(ctx context.Context, changes []*watch.Change) error {
+
+		for _, c := range changes {
+			log.Println(c)
+		}
+
+		return build(ctx)
+	})
+
+	return err
+}
@@ -0,0 +1,66 @@
+package md2idx
+
+import (
+	"flag"
+
+	"github.com/whosonfirst/go-whosonfirst-markdown/flags"
+)
+
+var input string
+var output string
+var header string
+var footer string
+var list string
+var rollup string
+var rollup_sort string
+var per_page int
+var mode string
+var key string
+var taxonomies string
+var drafts bool
+var future bool
+var build_time string
+var authors_data string
+var cache_path string
+var watch_changes bool
+var places_data string
+var templates flags.HTMLTemplateFlags
+var md_templates flags.MarkdownTemplateFlags
+var writers flags.WriterFlags
+
+// DefaultFlagSet returns a new flag.FlagSet with all the flags for wof-md2idx.
+
+func DefaultFlagSet() *flag.FlagSet {
+
+	fs := flag.NewFlagSet("wof-md2idx", flag.ExitOnError)
+
+	// multi-value flags are appended to so start from scratch every time
+
+	templates = nil
+	md_templates = nil
+	writers = nil
+
+	fs.StringVar(&input, "input", "index.md", "What you expect the input Markdown file to be called")
+	fs.StringVar(&output, "output", "index.html", "What you expect the output HTML file to be called")
+	fs.StringVar(&header, "header", "", "The name of the (Go) template to use as a custom header")
+	fs.StringVar(&footer, "footer", "", "The name of the (Go) template to use as a custom footer")
+	fs.StringVar(&list, "list", "", "The name of the (Go) template to use as a custom list view")
+	fs.StringVar(&rollup, "rollup", "", "The name of the (Go) template to use as a custom rollup view (for things like tags and authors)")
+	fs.StringVar(&rollup_sort, "rollup-sort", "alpha", "The order in which to list items in a rollup view. Valid sorts are: alpha, count (most posts first), recency (most recent post first)")
+	fs.IntVar(&per_page, "per-page", 0, "The number of posts (or rollup items) to list on each page. If 0 then everything is listed on a single page")
+	fs.StringVar(&mode, "mode", "date", "Valid modes are: authors, category, date, key, places, series, tags")
+	fs.StringVar(&key, "key", "", "The name of the front matter key to group posts by. Required by the key mode")
+	fs.StringVar(&taxonomies, "taxonomy", "", "The path to a JSON file defining aliases, canonical names, descriptions and slugs for authors, categories, tags (and other front matter keys)")
+	fs.BoolVar(&drafts, "drafts", false, "Include drafts (posts with \"published: false\" front matter or in a drafts directory)")
+	fs.BoolVar(&future, "future", false, "Include posts dated after the build time")
+	fs.StringVar(&build_time, "build-time", "", "The time (an RFC3339 timestamp or a YYYY-MM-DD date) that posts are considered published relative to. If empty the current time is used")
+	fs.StringVar(&authors_data, "authors-data", "", "The path to a directory containing author profiles (JSON or Markdown files named for each author)")
+	fs.StringVar(&cache_path, "cache", "", "The path to a build manifest used to skip index pages whose posts haven't changed since the last build and to remove pages that no longer have any posts. If empty every page is rendered")
+	fs.BoolVar(&watch_changes, "watch", false, "After rendering, watch the directories being indexed, templates, the taxonomy and author profiles for changes and re-render the index pages affected by them")
+	fs.StringVar(&places_data, "places-data", "", "The path to a local Who's On First data directory used to resolve place names and hierarchies. Required by the places mode")
+	fs.Var(&templates, "templates", "One or more directories containing (Go) templates to parse")
+	fs.Var(&md_templates, "markdown-templates", "One or more directories containing (Go) Markdown templates to parse")
+	fs.Var(&writers, "writer", "One or more writer to output rendered Markdown to. Valid writers are: fs=PATH; null; stdout")
+
+	return fs
+}
@@ -0,0 +1,1626 @@
+package md2idx
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+	_ "unicode"
+
+	"github.com/whosonfirst/go-whosonfirst-markdown"
+	"github.com/whosonfirst/go-whosonfirst-markdown/authors"
+	"github.com/whosonfirst/go-whosonfirst-markdown/cache"
+	"github.com/whosonfirst/go-whosonfirst-markdown/jekyll"
+	"github.com/whosonfirst/go-whosonfirst-markdown/parser"
+	"github.com/whosonfirst/go-whosonfirst-markdown/places"
+	"github.com/whosonfirst/go-whosonfirst-markdown/publish"
+	"github.com/whosonfirst/go-whosonfirst-markdown/render"
+	"github.com/whosonfirst/go-whosonfirst-markdown/site"
+	"github.com/whosonfirst/go-whosonfirst-markdown/taxonomy"
+	"github.com/whosonfirst/go-whosonfirst-markdown/uri"
+	"github.com/whosonfirst/go-whosonfirst-markdown/watch"
+	"github.com/whosonfirst/go-whosonfirst-markdown/writer"
+)
+
+var default_index_list string
+var default_index_rollup string
+var default_index_places_rollup string
+var default_pagination string
+
+func init() {
+
+	default_pagination = `{{ with .Pagination }}{{ if gt .Pages 1 }}
+{{ if .Previous }}[&larr; Previous]({{ .Previous }}) {{ end }}Page {{ .Page }} of {{ .Pages }}{{ if .Next }} [Next &rarr;]({{ .Next }}){{ end }}
+{{ end }}{{ end }}`
+
+	default_index_rollup = `{{ range $i := .Items }}
+* [ {{ $i.Key }} ]( {{ $.Pagination.Base }}{{ $i.Path }}/ ) ({{ $i.Count }})
+{{ end }}` + default_pagination
+
+	default_index_places_rollup = `{{ range $p := .Places }}{{ $p.Indent }}* [ {{ $p.Place.Name }} ]( {{ $.Pagination.Base }}{{ $p.Place.Id }}/ ) ({{ $p.Count }})
+{{ end }}` + default_pagination
+
+	default_index_list = `{{ with .Author }}{{ if .Avatar }}![{{ .Name }}]({{ .Avatar }})
+
+{{ end }}{{ .Bio }}
+{{ range $l := .Links }}
+* [{{ $l.Title }}]({{ $l.URL }}){{ end }}
+{{ end }}{{ with .Archive }}{{ if or .Previous .Next }}
+{{ if .Previous }}[&larr; {{ .Previous.Title }}]({{ .Previous.URL }}){{ end }}{{ if and .Previous .Next }} | {{ end }}{{ if .Next }}[{{ .Next.Title }} &rarr;]({{ .Next.URL }}){{ end }}
+{{ end }}{{ if .Periods }}
+{{ range $p := .Periods }}* [{{ $p.Title }}]({{ $p.URL }}) ({{ $p.Count }})
+{{ end }}{{ end }}{{ end }}{{ range $fm := .Posts }}
+### {{ if $fm.SeriesPart }}Part {{ $fm.SeriesPart }}: {{ end }}[{{ $fm.Title }}]({{ $fm.Permalink }}) 
+
+> {{ $fm.Excerpt }}
+
+{{$lena := len $fm.Authors }}
+{{$lent := len $fm.Tags }}
+<small class="this-is">This is a blog post by
+    {{ range $ia, $a := $fm.Authors }}{{ if gt $lena 1 }}{{if eq $ia 0}}{{else if eq (plus1 $ia) $lena}} and {{else}}, {{end}}{{ end }}[{{ $a }}](/blog/authors/{{ prune_string $a  }}/){{ end }}.
+    {{ if $fm.Date }}It was published on <span class="pubdate"><a href="/blog/{{ $fm.Date.Year }}/{{ $fm.Date.Format "01" }}/">{{ $fm.Date.Format "January" }}</a> <a href="/blog/{{ $fm.Date.Year }}/{{ $fm.Date.Format "01" }}/{{ $fm.Date.Format "02" }}/">{{ $fm.Date.Format "02"}}</a>, <a href="/blog/{{ $fm.Date.Year }}/">{{ $fm.Date.Format "2006" }}</a></span>{{ if gt $lent 0 }} and tagged {{ range $it, $t := $fm.Tags }}{{ if gt $lent 1 }}{{if eq $it 0}}{{else if eq (plus1 $it) $lent}} and {{else}}, {{end}}{{ end }}[{{ $t }}](/blog/tags/{{ prune_string $t  }}/){{ end }}{{ end}}.
+    {{ else }}
+    It was tagged {{ range $it, $t := $fm.Tags }}{{ if gt $lent 1 }}{{if eq $it 0}}{{else if eq (plus1 $it) $lent}} and {{else}}, {{end}}{{ end }}[{{ $t }}](/blog/tags/{{ prune_string $t  }}/){{ end }}.
+    {{ end }}
+</small>
+{{ end }}` + default_pagination
+}
+
+type MarkdownOptions struct {
+	MarkdownTemplates *template.Template
+	List              string
+	Rollup            string
+	Mode              string
+	Key               string
+	RollupSort        string
+	PerPage           int
+	Places            *places.LocalResolver
+	Taxonomies        *taxonomy.Taxonomies
+	Authors           *authors.Profiles
+}
+
+// Taxonomy returns the taxonomy for the current mode (or key) which may be nil
+
+func (md_opts *MarkdownOptions) Taxonomy() *taxonomy.Taxonomy {
+
+	switch md_opts.Mode {
+	case "authors", "category", "series", "tags":
+		return md_opts.Taxonomies.Taxonomy(md_opts.Mode)
+	case "key":
+		return md_opts.Taxonomies.Taxonomy(md_opts.Key)
+	default:
+		return nil
+	}
+}
+
+// RollupData is passed to rollup templates. Rollup is the list of keys and Items
+// the same keys, in the same order, with details about their posts. Places is
+// only defined in places mode.
+
+type RollupData struct {
+	Mode       string
+	Rollup     []string
+	Items      []*RollupItem
+	Places     []*PlaceRollup
+	Pagination *Pagination
+}
+
+// RollupItem is a single key (an author or a tag, say) in a rollup. First and Last
+// are the dates of the oldest and most recent posts (and may be nil) and Weight is
+// the number of posts normalised to a value between 0.0 (fewest) and 1.0 (most).
+
+type RollupItem struct {
+	Key         string
+	Path        string
+	Description string
+	Profile     *authors.Profile
+	Count       int
+	First       *time.Time
+	Last        *time.Time
+	Weight      float64
+}
+
+// Bucket maps an item's weight to one of n (1 to n) buckets, for example the
+// font sizes in a tag cloud.
+
+func (i *RollupItem) Bucket(n int) int {
+
+	if n <= 1 {
+		return 1
+	}
+
+	return 1 + int(i.Weight*float64(n-1)+0.5)
+}
+
+// the valid sort orders for rollups
+
+var rollup_sorts = []string{
+	"alpha",
+	"count",
+	"recency",
+}
+
+// NewRollupItems returns a RollupItem for each key in lookup sorted by sort_by
+// (alpha, count or recency). Ties are broken alphabetically.
+
+func NewRollupItems(lookup map[string][]*jekyll.FrontMatter, sort_by string, md_opts *MarkdownOptions) ([]*RollupItem, error) {
+
+	items := make([]*RollupItem, 0)
+
+	min := -1
+	max := 0
+
+	for k, posts := range lookup {
+
+		path, err := keyPath(k, md_opts)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if path == "" {
+			continue
+		}
+
+		i := &RollupItem{
+			Key:         k,
+			Path:        path,
+			Description: md_opts.Taxonomy().Description(k),
+			Count:       len(posts),
+		}
+
+		if md_opts.Mode == "authors" {
+			i.Profile = md_opts.Authors.Profile(k)
+		}
+
+		for _, fm := range posts {
+
+			if fm.Date == nil {
+				continue
+			}
+
+			if i.First == nil || fm.Date.Before(*i.First) {
+				i.First = fm.Date
+			}
+
+			if i.Last == nil || fm.Date.After(*i.Last) {
+				i.Last = fm.Date
+			}
+		}
+
+		if min == -1 || i.Count < min {
+			min = i.Count
+		}
+
+		if i.Count > max {
+			max = i.Count
+		}
+
+		items = append(items, i)
+	}
+
+	for _, i := range items {
+
+		if max == min {
+			i.Weight = 1.0
+		} else {
+			i.Weight = float64(i.Count-min) / float64(max-min)
+		}
+	}
+
+	var compare func(a *RollupItem, b *RollupItem) int
+
+	switch sort_by {
+	case "", "alpha":
+		compare = func(a *RollupItem, b *RollupItem) int {
+			return 0
+		}
+	case "count":
+		compare = func(a *RollupItem, b *RollupItem) int {
+			return b.Count - a.Count
+		}
+	case "recency":
+		compare = func(a *RollupItem, b *RollupItem) int {
+
+			switch {
+			case a.Last == nil && b.Last == nil:
+				return 0
+			case a.Last == nil:
+				return 1
+			case b.Last == nil:
+				return -1
+			case a.Last.After(*b.Last):
+				return -1
+			case a.Last.Before(*b.Last):
+				return 1
+			default:
+				return 0
+			}
+		}
+	default:
+		return nil, errors.New(fmt.Sprintf("Invalid or unsupported rollup sort '%s'. Valid sorts are: %s", sort_by, strings.Join(rollup_sorts, ", ")))
+	}
+
+	sort.Slice(items, func(i, j int) bool {
+
+		c := compare(items[i], items[j])
+
+		if c != 0 {
+			return c < 0
+		}
+
+		return items[i].Key < items[j].Key
+	})
+
+	return items, nil
+}
+
+// Pagination describes a single page of a paginated list. Page numbers start at
+// 1. The first page is written to the root of a list (for example tags/foo/index.html)
+// and subsequent pages to page/{N}/ (for example tags/foo/page/2/index.html).
+// First, Previous, Next and Last are URLs relative to the current page; Previous
+// and Next are empty if there is no previous or next page. Base is the prefix for
+// URLs relative to the root of the list (for example the items in a rollup),
+// "../../" for every page after the first.
+
+type Pagination struct {
+	Page     int
+	Pages    int
+	PerPage  int
+	Total    int
+	Base     string
+	First    string
+	Previous string
+	Next     string
+	Last     string
+}
+
+// Start and End return the (slice) indices of the items on this page
+
+func (p *Pagination) Start() int {
+	return (p.Page - 1) * p.PerPage
+}
+
+func (p *Pagination) End() int {
+
+	end := p.Page * p.PerPage
+
+	if end > p.Total {
+		end = p.Total
+	}
+
+	return end
+}
+
+// Paginate returns the pages needed to list total items, per_page items at a
+// time. If per_page is 0 (or less) everything is listed on a single page. There
+// is always at least one page.
+
+func Paginate(total int, per_page int) []*Pagination {
+
+	if per_page <= 0 {
+		per_page = total
+	}
+
+	count := 1
+
+	if per_page > 0 && total > per_page {
+		count = (total + per_page - 1) / per_page
+	}
+
+	pages := make([]*Pagination, count)
+
+	for i := 1; i <= count; i++ {
+
+		p := Pagination{
+			Page:    i,
+			Pages:   count,
+			PerPage: per_page,
+			Total:   total,
+			First:   pageURL(i, 1),
+			Last:    pageURL(i, count),
+		}
+
+		if i > 1 {
+			p.Base = "../../"
+		}
+
+		if i > 1 {
+			p.Previous = pageURL(i, i-1)
+		}
+
+		if i < count {
+			p.Next = pageURL(i, i+1)
+		}
+
+		pages[i-1] = &p
+	}
+
+	return pages
+}
+
+// PageDir returns the directory that page should be written to
+
+func PageDir(root string, page int) string {
+
+	if page <= 1 {
+		return root
+	}
+
+	return filepath.Join(root, "page", strconv.Itoa(page))
+}
+
+// pageURL returns the URL for page "to" relative to page "from"
+
+func pageURL(from int, to int) string {
+
+	prefix := ""
+
+	if from > 1 {
+		prefix = "../../"
+	}
+
+	if to <= 1 {
+
+		if prefix == "" {
+			return "./"
+		}
+
+		return prefix
+	}
+
+	if from > 1 {
+		return fmt.Sprintf("../%d/", to)
+	}
+
+	return fmt.Sprintf("page/%d/", to)
+}
+
+// Archive describes a page in a date archive: the root of the archive or a year,
+// month or day. Periods are the (child) periods that have posts, for example the
+// months of a year, and Previous and Next are the nearest earlier and later periods
+// of the same kind that have posts. All URLs are relative to the current page.
+
+type Archive struct {
+	Period   string
+	Title    string
+	Date     *time.Time
+	Count    int
+	Periods  []*ArchivePeriod
+	Previous *ArchivePeriod
+	Next     *ArchivePeriod
+	depth    int
+}
+
+type ArchivePeriod struct {
+	Period string
+	Title  string
+	Date   time.Time
+	Count  int
+	Path   string
+	URL    string
+}
+
+// the kinds of archive periods, in order, along with the formats used for
+// their paths and titles
+
+var archive_periods = []string{
+	"year",
+	"month",
+	"day",
+}
+
+var archive_paths = map[string]string{
+	"year":  "2006",
+	"month": "2006/01",
+	"day":   "2006/01/02",
+}
+
+var archive_titles = map[string]string{
+	"year":  "2006",
+	"month": "January 2006",
+	"day":   "January 2, 2006",
+}
+
+// relativeTo returns a copy of a whose URLs are relative to a page depth
+// directories below the root of the archive.
+
+func (a *Archive) relativeTo(depth int) *Archive {
+
+	link := func(p *ArchivePeriod) *ArchivePeriod {
+
+		if p == nil {
+			return nil
+		}
+
+		l := *p
+		l.URL = strings.Repeat("../", depth) + p.Path + "/"
+		return &l
+	}
+
+	rel := *a
+	rel.Previous = link(a.Previous)
+	rel.Next = link(a.Next)
+	rel.Periods = make([]*ArchivePeriod, len(a.Periods))
+
+	for i, p := range a.Periods {
+		rel.Periods[i] = link(p)
+	}
+
+	return &rel
+}
+
+// RenderArchive renders an index of all the posts in root as well as year, month
+// and day pages (for example 2018/, 2018/02/ and 2018/02/03/) for each period that
+// has posts. Posts without a date are not included.
+
+func RenderArchive(ctx context.Context, root string, posts []*jekyll.FrontMatter, html_opts *render.HTMLOptions, md_opts *MarkdownOptions) error {
+
+	periods := make(map[string][]*ArchivePeriod)
+	lookup := make(map[string][]*jekyll.FrontMatter)
+
+	dated := make([]*jekyll.FrontMatter, 0)
+
+	for _, fm := range posts {
+
+		if fm.Date == nil {
+			continue
+		}
+
+		dated = append(dated, fm)
+
+		for _, kind := range archive_periods {
+
+			path := fm.Date.Format(archive_paths[kind])
+
+			if _, ok := lookup[path]; !ok {
+
+				dt := *fm.Date
+				y, m, d := dt.Date()
+
+				switch kind {
+				case "year":
+					dt = time.Date(y, 1, 1, 0, 0, 0, 0, dt.Location())
+				case "month":
+					dt = time.Date(y, m, 1, 0, 0, 0, 0, dt.Location())
+				default:
+					dt = time.Date(y, m, d, 0, 0, 0, 0, dt.Location())
+				}
+
+				p := &ArchivePeriod{
+					Period: kind,
+					Title:  dt.Format(archive_titles[kind]),
+					Date:   dt,
+					Path:   path,
+				}
+
+				periods[kind] = append(periods[kind], p)
+			}
+
+			lookup[path] = append(lookup[path], fm)
+		}
+	}
+
+	for _, kind := range archive_periods {
+
+		candidates := make([]*ArchivePeriod, 0)
+
+		for _, p := range periods[kind] {
+
+			// a period directory that contains a post (for example a post
+			// whose path is 2018/01/02/index.md) is where that post is
+			// rendered so don't overwrite it, or link to it as an archive
+
+			input_path := filepath.Join(root, filepath.FromSlash(p.Path), html_opts.Input)
+			_, err := os.Stat(input_path)
+
+			if err == nil {
+				log.Printf("Skipping the archive page for %s because it would overwrite %s\n", p.Path, input_path)
+				continue
+			}
+
+			p.Count = len(lookup[p.Path])
+			candidates = append(candidates, p)
+		}
+
+		sort.Slice(candidates, func(i, j int) bool {
+			return candidates[i].Date.Before(candidates[j].Date)
+		})
+
+		periods[kind] = candidates
+	}
+
+	// the root of the archive
+
+	a := &Archive{
+		Count:   len(dated),
+		Periods: periods["year"],
+	}
+
+	err := RenderPosts(ctx, root, "", dated, a, html_opts, md_opts)
+
+	if err != nil {
+		return err
+	}
+
+	for i, kind := range archive_periods {
+
+		candidates := periods[kind]
+
+		for j, p := range candidates {
+
+			dt := p.Date
+
+			a := &Archive{
+				Period:  kind,
+				Title:   p.Title,
+				Date:    &dt,
+				Count:   p.Count,
+				Periods: make([]*ArchivePeriod, 0),
+				depth:   i + 1,
+			}
+
+			if j > 0 {
+				a.Previous = candidates[j-1]
+			}
+
+			if j < len(candidates)-1 {
+				a.Next = candidates[j+1]
+			}
+
+			if i < len(archive_periods)-1 {
+
+				for _, c := range periods[archive_periods[i+1]] {
+
+					if strings.HasPrefix(c.Path, p.Path+"/") {
+						a.Periods = append(a.Periods, c)
+					}
+				}
+			}
+
+			p_root := filepath.Join(root, filepath.FromSlash(p.Path))
+
+			err := RenderPosts(ctx, p_root, p.Title, lookup[p.Path], a, html_opts, md_opts)
+
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// PlaceRollup is a single place in a places rollup, flattened from the
+// country > region > locality hierarchy in the order it should be listed.
+
+type PlaceRollup struct {
+	Place  *places.Place
+	Depth  int
+	Indent string
+	Count  int
+}
+
+func RenderDirectory(ctx context.Context, dir string, html_opts *render.HTMLOptions, md_opts *MarkdownOptions) error {
+
+	lookup, err := GatherPosts(ctx, dir, html_opts, md_opts)
+
+	if err != nil {
+		return err
+	}
+
+	keys := make([]string, 0)
+
+	for k, _ := range lookup {
+		keys = append(keys, k)
+	}
+
+	sort.Sort(sort.Reverse(sort.StringSlice(keys)))
+
+	if md_opts.Mode == "date" {
+
+		posts := make([]*jekyll.FrontMatter, 0)
+
+		for _, k := range keys {
+
+			for _, p := range lookup[k] {
+				posts = append(posts, p)
+			}
+		}
+
+		if len(posts) == 0 {
+			return nil
+		}
+
+		jekyll.SortPosts(posts)
+
+		return RenderArchive(ctx, dir, posts, html_opts, md_opts)
+	}
+
+	root := filepath.Join(dir, md_opts.Mode)
+
+	switch md_opts.Mode {
+	case "authors", "category", "places", "series", "tags":
+		// pass
+	case "key":
+
+		clean, err := uri.PruneString(md_opts.Key)
+
+		if err != nil {
+			return err
+		}
+
+		if clean == "" {
+			return errors.New("Missing or invalid front matter key, required by key mode")
+		}
+
+		root = filepath.Join(dir, clean)
+
+	default:
+		return errors.New("Invalid or unsupported mode")
+	}
+
+	if md_opts.Mode != "places" {
+
+		slug := func(raw string) (string, error) {
+			return keyPath(raw, md_opts)
+		}
+
+		err := taxonomy.CheckSlugs(keys, slug)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	if md_opts.Mode == "authors" && md_opts.Authors != nil {
+
+		for _, raw := range keys {
+
+			if md_opts.Authors.Profile(raw) == nil {
+				log.Printf("WARNING '%s' does not have an author profile\n", raw)
+			}
+		}
+	}
+
+	for _, raw := range keys {
+
+		clean, err := keyPath(raw, md_opts)
+
+		if err != nil {
+			return err
+		}
+
+		if clean == "" {
+			continue
+		}
+
+		// html_opts.Title = raw
+
+		k_dir := filepath.Join(root, filepath.FromSlash(clean))
+
+		title := raw
+		posts := lookup[raw]
+
+		// the parts of a series are listed in order (part 1, part 2 and so on)
+
+		if md_opts.Mode == "series" {
+
+			parts := make([]*jekyll.FrontMatter, len(posts))
+			copy(parts, posts)
+
+			jekyll.SortSeries(parts)
+			posts = parts
+		}
+
+		if md_opts.Mode == "places" {
+
+			pl, err := placeForKey(raw, md_opts)
+
+			if err != nil {
+				return err
+			}
+
+			title = pl.Name
+		}
+
+		err = RenderPosts(ctx, k_dir, title, posts, nil, html_opts, md_opts)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	if md_opts.Mode == "places" {
+		return RenderPlacesRollup(ctx, root, keys, lookup, html_opts, md_opts)
+	}
+
+	return RenderRollup(ctx, root, lookup, html_opts, md_opts)
+}
+
+// GatherPosts returns the front matter for every published post in root grouped
+// by the keys for md_opts.Mode (tags, authors, dates and so on).
+
+func GatherPosts(ctx context.Context, root string, html_opts *render.HTMLOptions, md_opts *MarkdownOptions) (map[string][]*jekyll.FrontMatter, error) {
+
+	site_opts := site.DefaultSiteOptions()
+	site_opts.Input = html_opts.Input
+	site_opts.Filter = html_opts.Filter
+
+	s, err := site.LoadSite(ctx, root, site_opts)
+
+	if err != nil {
+		return nil, err
+	}
+
+	key := func(doc *markdown.Document) ([]string, error) {
+
+		fm := doc.FrontMatter
+
+		switch md_opts.Mode {
+		case "authors":
+			return canonicalKeys(fm.Authors, md_opts), nil
+		case "category":
+			return canonicalKeys([]string{fm.Category}, md_opts), nil
+		case "key":
+			return canonicalKeys(fm.Values(md_opts.Key), md_opts), nil
+		case "date":
+
+			if fm.Date == nil {
+				return nil, nil
+			}
+
+			return []string{fm.Date.Format("20060102")}, nil
+
+		case "places":
+			return placesKeys(fm, md_opts)
+		case "series":
+			return canonicalKeys([]string{fm.Series}, md_opts), nil
+		case "tags":
+			return canonicalKeys(fm.Tags, md_opts), nil
+		default:
+			return nil, errors.New("Invalid or unsupported mode")
+		}
+	}
+
+	// posts in each group are sorted reverse chronologically, see
+	// jekyll.ComparePosts for details
+
+	groups, err := s.Group(key)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return groups.FrontMatter(), nil
+}
+
+// see notes below about passing a struct for post details
+
+// RenderPosts renders (and paginates) a list of posts. archive is only defined
+// in date mode and may be nil.
+
+func RenderPosts(ctx context.Context, root string, title string, posts []*jekyll.FrontMatter, archive *Archive, html_opts *render.HTMLOptions, md_opts *MarkdownOptions) error {
+
+	m, _ := ctx.Value("cache").(*cache.Manifest)
+
+	var source string
+
+	if m != nil {
+
+		// the pages only need to be re-rendered if the front matter for
+		// the posts (or the archive navigation) has changed
+
+		h, err := cache.HashJSON(title, posts, archive)
+
+		if err != nil {
+			return err
+		}
+
+		source = h
+	}
+
+	return m.Build(ctx, cacheKey(root, md_opts), source, func(ctx context.Context) error {
+		return renderPosts(ctx, root, title, posts, archive, html_opts, md_opts)
+	})
+}
+
+func renderPosts(ctx context.Context, root string, title string, posts []*jekyll.FrontMatter, archive *Archive, html_opts *render.HTMLOptions, md_opts *MarkdownOptions) error {
+
+	for _, pg := range Paginate(len(posts), md_opts.PerPage) {
+
+		var page_archive *Archive
+
+		if archive != nil {
+
+			depth := archive.depth
+
+			if pg.Page > 1 {
+				depth += 2 // page/{N}/
+			}
+
+			page_archive = archive.relativeTo(depth)
+		}
+
+		err := RenderPostsPage(ctx, root, title, posts[pg.Start():pg.End()], pg, page_archive, html_opts, md_opts)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func RenderPostsPage(ctx context.Context, root string, title string, posts []*jekyll.FrontMatter, pg *Pagination, archive *Archive, html_opts *render.HTMLOptions, md_opts *MarkdownOptions) error {
+
+	select {
+	case <-ctx.Done():
+		return nil
+	default:
+		// pass
+	}
+
+	var t *template.Template
+
+	if md_opts.List != "" {
+		t = md_opts.MarkdownTemplates.Lookup(md_opts.List)
+	}
+
+	if t == nil {
+
+		func_map := template.FuncMap{
+			"prune_string": uri.PruneString,
+			"plus1": func(x int) int {
+				return x + 1
+			},
+		}
+
+		tm, err := template.New("list").Funcs(func_map).Parse(default_index_list)
+
+		if err != nil {
+			return err
+		}
+
+		t = tm
+	}
+
+	// maybe just pass this to RenderPosts?
+	// (20190409/thisisaaronland)
+
+	type Data struct {
+		Mode        string
+		Title       string
+		Posts       []*jekyll.FrontMatter
+		Description string
+		Author      *authors.Profile
+		Authors     *authors.Profiles
+		Pagination  *Pagination
+		Archive     *Archive
+	}
+
+	d := Data{
+		Mode:        md_opts.Mode,
+		Title:       title,
+		Description: md_opts.Taxonomy().Description(title),
+		Authors:     md_opts.Authors,
+		Posts:       posts,
+		Pagination:  pg,
+		Archive:     archive,
+	}
+
+	if md_opts.Mode == "authors" {
+		d.Author = md_opts.Authors.Profile(title)
+	}
+
+	var b bytes.Buffer
+	wr := bufio.NewWriter(&b)
+
+	err := t.Execute(wr, d)
+
+	if err != nil {
+		return err
+	}
+
+	wr.Flush()
+
+	r := bytes.NewReader(b.Bytes())
+	fh := ioutil.NopCloser(r)
+
+	parse_opts := parser.DefaultParseOptions()
+	fm, buf, err := parser.Parse(fh, parse_opts)
+
+	if err != nil {
+		log.Printf("FAILED to parse MD document, because %s\n", err)
+		return err
+	}
+
+	if fm.Title == "" {
+		fm.Title = title
+	}
+
+	// year, month and day pages are dated by the period they list. This comes
+	// from the archive rather than root since other modes, for example places,
+	// can have keys that look like dates.
+
+	if archive != nil && archive.Date != nil {
+		dt := *archive.Date
+		fm.Date = &dt
+	}
+
+	doc, err := markdown.NewDocument(fm, buf)
+
+	if err != nil {
+		log.Printf("FAILED to create MD document, because %s\n", err)
+		return err
+	}
+
+	page_ctx := render.NewPageContext(doc, html_opts)
+	page_ctx.Author = d.Author
+
+	html, err := render.RenderHTMLWithContext(doc, html_opts, page_ctx)
+
+	if err != nil {
+		log.Printf("FAILED to render HTML document, because %s\n", err)
+		return err
+	}
+
+	w := ctx.Value("writer").(writer.Writer)
+
+	if w == nil {
+		return errors.New("Can't load writer from context")
+	}
+
+	out_path := filepath.Join(PageDir(root, pg.Page), html_opts.Output)
+	return w.Write(out_path, html)
+}
+
+func RenderRollup(ctx context.Context, root string, lookup map[string][]*jekyll.FrontMatter, html_opts *render.HTMLOptions, md_opts *MarkdownOptions) error {
+
+	items, err := NewRollupItems(lookup, md_opts.RollupSort, md_opts)
+
+	if err != nil {
+		return err
+	}
+
+	rollup := make([]string, len(items))
+
+	for idx, i := range items {
+		rollup[idx] = i.Key
+	}
+
+	d := RollupData{
+		Mode:   md_opts.Mode,
+		Rollup: rollup,
+		Items:  items,
+		Places: make([]*PlaceRollup, 0),
+	}
+
+	return renderRollupData(ctx, root, default_index_rollup, d, html_opts, md_opts)
+}
+
+// RenderPlacesRollup renders a rollup of places nesting each place under the nearest
+// of its ancestors that also has posts (country > region > locality).
+
+func RenderPlacesRollup(ctx context.Context, root string, rollup []string, lookup map[string][]*jekyll.FrontMatter, html_opts *render.HTMLOptions, md_opts *MarkdownOptions) error {
+
+	sort.Sort(sort.StringSlice(rollup))
+
+	children := make(map[int64][]*places.Place)
+
+	for _, k := range rollup {
+
+		pl, err := placeForKey(k, md_opts)
+
+		if err != nil {
+			return err
+		}
+
+		ancestors, err := md_opts.Places.Ancestors(pl.Id)
+
+		if err != nil {
+			return err
+		}
+
+		parent_id := int64(0)
+
+		// the last ancestor is the place itself
+
+		for i := len(ancestors) - 2; i >= 0; i-- {
+
+			a_id := strconv.FormatInt(ancestors[i].Id, 10)
+
+			if _, ok := lookup[a_id]; ok {
+				parent_id = ancestors[i].Id
+				break
+			}
+		}
+
+		children[parent_id] = append(children[parent_id], pl)
+	}
+
+	items := make([]*PlaceRollup, 0)
+
+	var flatten func(int64, int)
+
+	flatten = func(parent_id int64, depth int) {
+
+		candidates := children[parent_id]
+
+		sort.Slice(candidates, func(i, j int) bool {
+			return candidates[i].Name < candidates[j].Name
+		})
+
+		for _, pl := range candidates {
+
+			k := strconv.FormatInt(pl.Id, 10)
+
+			item := &PlaceRollup{
+				Place:  pl,
+				Depth:  depth,
+				Indent: strings.Repeat("    ", depth),
+				Count:  len(lookup[k]),
+			}
+
+			items = append(items, item)
+			flatten(pl.Id, depth+1)
+		}
+	}
+
+	flatten(0, 0)
+
+	d := RollupData{
+		Mode:   md_opts.Mode,
+		Rollup: rollup,
+		Places: items,
+	}
+
+	return renderRollupData(ctx, root, default_index_places_rollup, d, html_opts, md_opts)
+}
+
+func renderRollupData(ctx context.Context, root string, default_rollup string, d RollupData, html_opts *render.HTMLOptions, md_opts *MarkdownOptions) error {
+
+	m, _ := ctx.Value("cache").(*cache.Manifest)
+
+	var source string
+
+	if m != nil {
+
+		h, err := cache.HashJSON(d)
+
+		if err != nil {
+			return err
+		}
+
+		source = h
+	}
+
+	// the rollup is written to the same directory as (for example) the date
+	// archive so it needs its own key
+
+	key := cacheKey(root, md_opts) + "#rollup"
+
+	return m.Build(ctx, key, source, func(ctx context.Context) error {
+		return renderRollupPages(ctx, root, default_rollup, d, html_opts, md_opts)
+	})
+}
+
+func renderRollupPages(ctx context.Context, root string, default_rollup string, d RollupData, html_opts *render.HTMLOptions, md_opts *MarkdownOptions) error {
+
+	// places rollups are paginated by place, everything else by key
+
+	total := len(d.Rollup)
+
+	if len(d.Places) > 0 {
+		total = len(d.Places)
+	}
+
+	for _, pg := range Paginate(total, md_opts.PerPage) {
+
+		page_d := d
+		page_d.Pagination = pg
+
+		if len(d.Places) > 0 {
+			page_d.Places = d.Places[pg.Start():pg.End()]
+		} else {
+			page_d.Rollup = d.Rollup[pg.Start():pg.End()]
+			page_d.Items = d.Items[pg.Start():pg.End()]
+		}
+
+		err := renderRollupPage(ctx, root, default_rollup, page_d, html_opts, md_opts)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func renderRollupPage(ctx context.Context, root string, default_rollup string, d RollupData, html_opts *render.HTMLOptions, md_opts *MarkdownOptions) error {
+
+	select {
+	case <-ctx.Done():
+		return nil
+	default:
+		// pass
+	}
+
+	var t *template.Template
+
+	if md_opts.Rollup != "" {
+		t = md_opts.MarkdownTemplates.Lookup(md_opts.Rollup)
+	}
+
+	if t == nil {
+
+		func_map := template.FuncMap{
+			"prune_string": uri.PruneString,
+			"plus1": func(x int) int {
+				return x + 1
+			},
+		}
+
+		func_map["key_path"] = func(raw string) (string, error) {
+			return keyPath(raw, md_opts)
+		}
+
+		tm, err := template.New("rollup").Funcs(func_map).Parse(default_rollup)
+
+		if err != nil {
+			return err
+		}
+
+		t = tm
+	}
+
+	var b bytes.Buffer
+	wr := bufio.NewWriter(&b)
+
+	err := t.Execute(wr, d)
+
+	if err != nil {
+		return err
+	}
+
+	wr.Flush()
+
+	r := bytes.NewReader(b.Bytes())
+	fh := ioutil.NopCloser(r)
+
+	parse_opts := parser.DefaultParseOptions()
+	fm, buf, err := parser.Parse(fh, parse_opts)
+
+	if err != nil {
+		log.Printf("FAILED to parse MD document, because %s\n", err)
+		return err
+	}
+
+	doc, err := markdown.NewDocument(fm, buf)
+
+	if err != nil {
+		log.Printf("FAILED to create MD document, because %s\n", err)
+		return err
+	}
+
+	html, err := render.RenderHTML(doc, html_opts)
+
+	if err != nil {
+		log.Printf("FAILED to render HTML document, because %s\n", err)
+		return err
+	}
+
+	w := ctx.Value("writer").(writer.Writer)
+
+	if w == nil {
+		return errors.New("Can't load writer from context")
+	}
+
+	out_path := filepath.Join(PageDir(root, d.Pagination.Page), html_opts.Output)
+	return w.Write(out_path, html)
+}
+
+// keyPath returns the (relative) path for a key. Categories and the values of
+// other front matter keys may be hierarchical, for example "engineering/data",
+// in which case each part of the path is pruned separately. Slugs defined in
+// the current taxonomy take precedence.
+
+func keyPath(raw string, md_opts *MarkdownOptions) (string, error) {
+
+	t := md_opts.Taxonomy()
+
+	switch md_opts.Mode {
+	case "category", "key":
+		return t.SlugPath(raw)
+	default:
+		return t.Slug(raw)
+	}
+}
+
+// canonicalKeys maps values to their canonical names in the current taxonomy
+// (so that "golang" and "Go" are the same tag, say) removing any duplicates.
+// In category and key modes hierarchical values, for example "engineering/data",
+// are expanded to include their parents.
+
+func canonicalKeys(values []string, md_opts *MarkdownOptions) []string {
+
+	t := md_opts.Taxonomy()
+
+	keys := make([]string, 0)
+	seen := make(map[string]bool)
+
+	for _, v := range values {
+
+		v = strings.TrimSpace(v)
+
+		if v == "" {
+			continue
+		}
+
+		candidates := []string{t.Canonical(v)}
+
+		switch md_opts.Mode {
+		case "category", "key":
+			candidates = uri.PathAncestors(candidates[0])
+
+			for i, c := range candidates {
+				candidates[i] = t.Canonical(c)
+			}
+		}
+
+		for _, k := range candidates {
+
+			if seen[k] {
+				continue
+			}
+
+			seen[k] = true
+			keys = append(keys, k)
+		}
+	}
+
+	return keys
+}
+
+// placesKeys returns the (stringified) WOF IDs for each of the places in fm
+// and their country, region and locality ancestors so that posts about a place
+// are also listed on the pages for the places that contain it.
+
+func placesKeys(fm *jekyll.FrontMatter, md_opts *MarkdownOptions) ([]string, error) {
+
+	if md_opts.Places == nil {
+		return nil, errors.New("Missing places data, required by places mode")
+	}
+
+	keys := make([]string, 0)
+	seen := make(map[int64]bool)
+
+	for _, id := range fm.Places {
+
+		ancestors, err := md_opts.Places.Ancestors(id)
+
+		if err != nil {
+			log.Printf("FAILED to resolve place %d for %s, because %s\n", id, fm.Permalink, err)
+			continue
+		}
+
+		for _, a := range ancestors {
+
+			if seen[a.Id] {
+				continue
+			}
+
+			seen[a.Id] = true
+			keys = append(keys, strconv.FormatInt(a.Id, 10))
+		}
+	}
+
+	return keys, nil
+}
+
+func placeForKey(k string, md_opts *MarkdownOptions) (*places.Place, error) {
+
+	if md_opts.Places == nil {
+		return nil, errors.New("Missing places data, required by places mode")
+	}
+
+	id, err := strconv.ParseInt(k, 10, 64)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return md_opts.Places.Place(id)
+}
+
+func Render(ctx context.Context, path string, html_opts *render.HTMLOptions, md_opts *MarkdownOptions) error {
+
+	if ctx.Err() != nil {
+		return errors.New("Interrupted, not every index page was rendered")
+	}
+
+	err := RenderDirectory(ctx, path, html_opts, md_opts)
+
+	if err != nil {
+		return err
+	}
+
+	// keys that were never reached look just like keys that no longer have any
+	// posts so only prune after a complete build (see cache.Manifest.Prune)
+
+	if ctx.Err() != nil {
+		return errors.New("Interrupted, not every index page was rendered")
+	}
+
+	// remove the pages for tags (authors, etc.) that no longer have any posts
+
+	m, _ := ctx.Value("cache").(*cache.Manifest)
+
+	pruned, err := m.Prune(ctx, cacheKey(path, md_opts))
+
+	if err != nil {
+		return err
+	}
+
+	for _, k := range pruned {
+		log.Printf("Removed pages for %s\n", k)
+	}
+
+	return nil
+}
+
+// cacheKey returns the key in the build manifest for the pages written to root.
+// Keys are prefixed by the mode so that, for example, the tags and authors
+// indices can share a manifest.
+
+func cacheKey(root string, md_opts *MarkdownOptions) string {
+
+	mode := md_opts.Mode
+
+	if mode == "key" {
+		mode = fmt.Sprintf("key=%s", md_opts.Key)
+	}
+
+	abs_root, err := filepath.Abs(root)
+
+	if err == nil {
+		root = abs_root
+	}
+
+	return fmt.Sprintf("idx:%s:%s%c", mode, root, filepath.Separator)
+}
+
+// Run parses the command line and runs wof-md2idx.
+
+func Run(ctx context.Context) error {
+
+	fs := DefaultFlagSet()
+	fs.Parse(os.Args[1:])
+
+	return RunWithFlagSet(ctx, fs)
+}
+
+// RunWithFlagSet runs wof-md2idx with the (already parsed) flags and arguments in fs.
+
+func RunWithFlagSet(ctx context.Context, fs *flag.FlagSet) error {
+
+	wr, err := writers.ToWriterWithContext(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	html_opts := render.DefaultHTMLOptions()
+	html_opts.Input = input
+	html_opts.Output = output
+	html_opts.Header = header
+	html_opts.Footer = footer
+
+	filter, err := publish.NewFilterWithContext(ctx, drafts, future, build_time)
+
+	if err != nil {
+		return err
+	}
+
+	html_opts.Filter = filter
+
+	md_opts := &MarkdownOptions{
+		List:       list,
+		Rollup:     rollup,
+		Mode:       mode,
+		Key:        key,
+		RollupSort: rollup_sort,
+		PerPage:    per_page,
+	}
+
+	valid_sort := false
+
+	for _, v := range rollup_sorts {
+
+		if rollup_sort == v {
+			valid_sort = true
+			break
+		}
+	}
+
+	if !valid_sort {
+		return errors.New(fmt.Sprintf("Invalid or unsupported rollup sort '%s'. Valid sorts are: %s", rollup_sort, strings.Join(rollup_sorts, ", ")))
+	}
+
+	if places_data != "" {
+
+		r, err := places.NewLocalResolver(places_data)
+
+		if err != nil {
+			return err
+		}
+
+		md_opts.Places = r
+	}
+
+	ctx = context.WithValue(ctx, "writer", wr)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// see notes in wof-md2html about watch mode
+
+	var m *cache.Manifest
+
+	if cache_path != "" {
+
+		m, err = cache.OpenManifest(cache_path, "", "")
+
+		if err != nil {
+			return err
+		}
+
+	} else if watch_changes {
+		m = cache.NewManifest("", "")
+	}
+
+	if m != nil {
+		ctx = context.WithValue(ctx, "cache", m)
+	}
+
+	// build renders everything once. In watch mode it is called again every
+	// time something changes which is why templates, the taxonomy and author
+	// profiles are loaded here.
+
+	build := func(ctx context.Context) error {
+
+		t, err := templates.Parse()
+
+		if err != nil {
+			return err
+		}
+
+		html_opts.Templates = t
+
+		markdown_t, err := md_templates.Parse()
+
+		if err != nil {
+			return err
+		}
+
+		md_opts.MarkdownTemplates = markdown_t
+		md_opts.Taxonomies = nil
+		md_opts.Authors = nil
+		html_opts.Authors = nil
+
+		if taxonomies != "" {
+
+			t, err := taxonomy.NewTaxonomiesFromFile(taxonomies)
+
+			if err != nil {
+				return err
+			}
+
+			md_opts.Taxonomies = t
+		}
+
+		if authors_data != "" {
+
+			p, err := authors.NewProfilesFromDirectory(authors_data)
+
+			if err != nil {
+				return err
+			}
+
+			md_opts.Authors = p
+			html_opts.Authors = p
+		}
+
+		if md_opts.Places != nil {
+
+			err := md_opts.Authors.ResolvePlaces(md_opts.Places)
+
+			if err != nil {
+				return err
+			}
+		}
+
+		if m != nil {
+
+			template_paths := append([]string(templates), md_templates...)
+			template_hash, err := cache.HashPaths(template_paths...)
+
+			if err != nil {
+				return err
+			}
+
+			// place names are not included because the places data directory
+			// is (very) large and rarely changes
+
+			data_hash, err := cache.HashPaths(authors_data, taxonomies)
+
+			if err != nil {
+				return err
+			}
+
+			flags_hash := cache.HashFlags(fs, "cache", "watch")
+			options_hash := cache.HashBytes([]byte(flags_hash), []byte(data_hash))
+
+			m.Reset(template_hash, options_hash)
+		}
+
+		for _, path := range fs.Args() {
+
+			err := Render(ctx, path, html_opts, md_opts)
+
+			if err != nil {
+				m.Save()
+				return err
+			}
+		}
+
+		return m.Save()
+	}
+
+	err = build(ctx)
+
+	if !watch_changes {
+		return err
+	}
+
+	if err != nil {
+		log.Println(err)
+	}
+
+	// only changes to the front matter of posts actually cause index pages to
+	// be re-rendered, see RenderPosts
+
+	watch_paths := append([]string(templates), md_templates...)
+	watch_paths = append(watch_paths, fs.Args()...)
+	watch_paths = append(watch_paths, authors_data, taxonomies)
+
+	w, err := watch.NewWatcher(watch_paths, watch.DefaultWatcherOptions())
+
+	if err != nil {
+		return err
+	}
+
+	log.Println("Watching for changes")
+
+	err = w.Watch(ctx, func(ctx context.Context, changes []*watch.Change) error {
+
+		for _, c := range changes {
+			log.Println(c)
+		}
+
+		return build(ctx)
+	})
+
+	return err
+}
@@ -0,0 +1,373 @@
+package md2idx
+
+import (
+	"context"
+	"html/template"
+	"io"
+	"net/url"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/whosonfirst/go-whosonfirst-markdown/cache"
+	"github.com/whosonfirst/go-whosonfirst-markdown/jekyll"
+	"github.com/whosonfirst/go-whosonfirst-markdown/render"
+	"github.com/whosonfirst/go-whosonfirst-markdown/writer"
+)
+
+type testWriter struct {
+	writer.Writer
+	files map[string]string
+}
+
+func (w *testWriter) Write(path string, fh io.ReadCloser) error {
+
+	defer fh.Close()
+
+	body, err := io.ReadAll(fh)
+
+	if err != nil {
+		return err
+	}
+
+	w.files[filepath.ToSlash(path)] = string(body)
+	return nil
+}
+
+func TestPaginate(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		total    int
+		per_page int
+		expected []Pagination
+	}{
+		{
+			name:     "everything on one page",
+			total:    5,
+			per_page: 0,
+			expected: []Pagination{
+				{Page: 1, Pages: 1, PerPage: 5, Total: 5, First: "./", Last: "./"},
+			},
+		},
+		{
+			name:     "nothing",
+			total:    0,
+			per_page: 10,
+			expected: []Pagination{
+				{Page: 1, Pages: 1, PerPage: 10, Total: 0, First: "./", Last: "./"},
+			},
+		},
+		{
+			name:     "exactly one page",
+			total:    10,
+			per_page: 10,
+			expected: []Pagination{
+				{Page: 1, Pages: 1, PerPage: 10, Total: 10, First: "./", Last: "./"},
+			},
+		},
+		{
+			name:     "three pages",
+			total:    5,
+			per_page: 2,
+			expected: []Pagination{
+				{Page: 1, Pages: 3, PerPage: 2, Total: 5, First: "./", Next: "page/2/", Last: "page/3/"},
+				{Page: 2, Pages: 3, PerPage: 2, Total: 5, Base: "../../", First: "../../", Previous: "../../", Next: "../3/", Last: "../3/"},
+				{Page: 3, Pages: 3, PerPage: 2, Total: 5, Base: "../../", First: "../../", Previous: "../2/", Last: "../3/"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+
+		t.Run(tt.name, func(t *testing.T) {
+
+			pages := Paginate(tt.total, tt.per_page)
+
+			if len(pages) != len(tt.expected) {
+				t.Fatalf("Expected %d pages, got %d", len(tt.expected), len(pages))
+			}
+
+			for idx, pg := range pages {
+
+				if !reflect.DeepEqual(*pg, tt.expected[idx]) {
+					t.Errorf("Expected page %d to be %+v, got %+v", idx+1, tt.expected[idx], *pg)
+				}
+			}
+		})
+	}
+}
+
+func TestPaginationStartEnd(t *testing.T) {
+
+	tests := []struct {
+		total    int
+		per_page int
+		expected [][2]int
+	}{
+		{total: 5, per_page: 0, expected: [][2]int{{0, 5}}},
+		{total: 4, per_page: 2, expected: [][2]int{{0, 2}, {2, 4}}},
+		{total: 5, per_page: 2, expected: [][2]int{{0, 2}, {2, 4}, {4, 5}}},
+	}
+
+	for _, tt := range tests {
+
+		for idx, pg := range Paginate(tt.total, tt.per_page) {
+
+			start_end := [2]int{pg.Start(), pg.End()}
+
+			if start_end != tt.expected[idx] {
+				t.Errorf("Expected page %d of %d items, %d per page, to be %v, got %v", idx+1, tt.total, tt.per_page, tt.expected[idx], start_end)
+			}
+		}
+	}
+}
+
+func TestPageURL(t *testing.T) {
+
+	tests := []struct {
+		from     int
+		to       int
+		expected string
+	}{
+		{from: 1, to: 1, expected: "./"},
+		{from: 1, to: 2, expected: "page/2/"},
+		{from: 2, to: 1, expected: "../../"},
+		{from: 2, to: 3, expected: "../3/"},
+		{from: 3, to: 2, expected: "../2/"},
+		{from: 3, to: 3, expected: "../3/"},
+	}
+
+	for _, tt := range tests {
+
+		v := pageURL(tt.from, tt.to)
+
+		if v != tt.expected {
+			t.Errorf("Expected the URL of page %d from page %d to be '%s', got '%s'", tt.to, tt.from, tt.expected, v)
+		}
+	}
+}
+
+func TestPageDir(t *testing.T) {
+
+	tests := []struct {
+		page     int
+		expected string
+	}{
+		{page: 1, expected: "/blog/tags"},
+		{page: 2, expected: "/blog/tags/page/2"},
+		{page: 10, expected: "/blog/tags/page/10"},
+	}
+
+	for _, tt := range tests {
+
+		v := filepath.ToSlash(PageDir(filepath.FromSlash("/blog/tags"), tt.page))
+
+		if v != tt.expected {
+			t.Errorf("Expected the directory for page %d to be '%s', got '%s'", tt.page, tt.expected, v)
+		}
+	}
+}
+
+// TestRenderRollupLinks checks that the items on every page of a rollup link to
+// the same place, whichever page they are listed on.
+
+func TestRenderRollupLinks(t *testing.T) {
+
+	lookup := map[string][]*jekyll.FrontMatter{
+		"alpha":   []*jekyll.FrontMatter{jekyll.EmptyFrontMatter()},
+		"bravo":   []*jekyll.FrontMatter{jekyll.EmptyFrontMatter()},
+		"charlie": []*jekyll.FrontMatter{jekyll.EmptyFrontMatter()},
+		"delta":   []*jekyll.FrontMatter{jekyll.EmptyFrontMatter()},
+		"echo":    []*jekyll.FrontMatter{jekyll.EmptyFrontMatter()},
+	}
+
+	html_opts := render.DefaultHTMLOptions()
+
+	md_opts := &MarkdownOptions{
+		Mode:       "tags",
+		RollupSort: "alpha",
+		PerPage:    2,
+	}
+
+	wr := &testWriter{
+		files: make(map[string]string),
+	}
+
+	ctx := context.WithValue(context.Background(), "writer", writer.Writer(wr))
+
+	err := RenderRollup(ctx, "/blog/tags", lookup, html_opts, md_opts)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		page     string
+		expected []string
+	}{
+		{page: "/blog/tags/", expected: []string{"/blog/tags/alpha/", "/blog/tags/bravo/"}},
+		{page: "/blog/tags/page/2/", expected: []string{"/blog/tags/charlie/", "/blog/tags/delta/"}},
+		{page: "/blog/tags/page/3/", expected: []string{"/blog/tags/echo/"}},
+	}
+
+	if len(wr.files) != len(tests) {
+		t.Fatalf("Expected %d pages, got %d", len(tests), len(wr.files))
+	}
+
+	re_item := regexp.MustCompile(`<li>(?:<p>)?<a href="([^"]+)">`)
+
+	for _, tt := range tests {
+
+		body, ok := wr.files[tt.page+html_opts.Output]
+
+		if !ok {
+			t.Errorf("Missing page %s", tt.page)
+			continue
+		}
+
+		base, err := url.Parse(tt.page)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		links := make([]string, 0)
+
+		for _, m := range re_item.FindAllStringSubmatch(body, -1) {
+
+			u, err := url.Parse(m[1])
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			links = append(links, base.ResolveReference(u).Path)
+		}
+
+		if !reflect.DeepEqual(links, tt.expected) {
+			t.Errorf("Expected the items on %s to link to %v, got %v", tt.page, tt.expected, links)
+		}
+	}
+}
+
+// TestRenderInterrupted checks that an interrupted build is an error and that
+// the pages of keys it didn't reach aren't pruned.
+
+func TestRenderInterrupted(t *testing.T) {
+
+	wr := &testWriter{
+		files: make(map[string]string),
+	}
+
+	html_opts := render.DefaultHTMLOptions()
+
+	md_opts := &MarkdownOptions{
+		Mode:       "tags",
+		RollupSort: "alpha",
+	}
+
+	root := t.TempDir()
+
+	m := cache.NewManifest("", "")
+
+	ctx := context.WithValue(context.Background(), "writer", writer.Writer(wr))
+	ctx = context.WithValue(ctx, "cache", m)
+
+	key := cacheKey(root, md_opts) + "go"
+
+	err := m.Build(ctx, key, "source", func(ctx context.Context) error {
+		return nil
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m.Reset("", "")
+
+	ctx, cancel := context.WithCancel(ctx)
+	cancel()
+
+	err = Render(ctx, root, html_opts, md_opts)
+
+	if err == nil {
+		t.Fatal("Expected an interrupted build to be an error")
+	}
+
+	_, ok := m.Entries[key]
+
+	if !ok {
+		t.Errorf("Expected %s not to be pruned after an interrupted build", key)
+	}
+}
+
+// TestRenderPostsPageDate checks that only year, month and day pages are dated,
+// even if the key for a page in another mode looks like a date.
+
+func TestRenderPostsPageDate(t *testing.T) {
+
+	header, err := template.New("header").Parse(`<date>{{ if .Date }}{{ .Date.Format "2006-01-02" }}{{ end }}</date>`)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	html_opts := render.DefaultHTMLOptions()
+	html_opts.Templates = header
+	html_opts.Header = "header"
+
+	dt := time.Date(2018, 2, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		mode     string
+		root     string
+		archive  *Archive
+		expected string
+	}{
+		{name: "month", mode: "date", root: "/blog/2018/02", archive: &Archive{Period: "month", Date: &dt}, expected: "2018-02-01"},
+		{name: "archive root", mode: "date", root: "/blog", archive: &Archive{}, expected: ""},
+		{name: "place", mode: "places", root: "/blog/places/85922583", expected: ""},
+		{name: "tag", mode: "tags", root: "/blog/tags/2018", expected: ""},
+	}
+
+	for _, tt := range tests {
+
+		t.Run(tt.name, func(t *testing.T) {
+
+			wr := &testWriter{
+				files: make(map[string]string),
+			}
+
+			ctx := context.WithValue(context.Background(), "writer", writer.Writer(wr))
+
+			md_opts := &MarkdownOptions{
+				Mode: tt.mode,
+			}
+
+			posts := []*jekyll.FrontMatter{jekyll.EmptyFrontMatter()}
+
+			err := RenderPostsPage(ctx, tt.root, "", posts, Paginate(len(posts), 0)[0], tt.archive, html_opts, md_opts)
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			body, ok := wr.files[tt.root+"/"+html_opts.Output]
+
+			if !ok {
+				t.Fatalf("Missing page %s", tt.root)
+			}
+
+			expected := "<date>" + tt.expected + "</date>"
+
+			if !strings.Contains(body, expected) {
+				t.Errorf("Expected %s in %s", expected, body)
+			}
+		})
+	}
+}
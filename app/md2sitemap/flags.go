@@ -0,0 +1,51 @@
+package md2sitemap
+
+import (
+	"flag"
+
+	"github.com/whosonfirst/go-whosonfirst-markdown/flags"
+	"github.com/whosonfirst/go-whosonfirst-markdown/render"
+)
+
+var input string
+var output string
+var max_urls int
+var site_url string
+var priority float64
+var priorities flags.SitemapPriorityFlags
+var drafts bool
+var future bool
+var build_time string
+var robots bool
+var robots_output string
+var disallow flags.MultiStringFlags
+var writers flags.WriterFlags
+
+// DefaultFlagSet returns a new flag.FlagSet with all the flags for wof-md2sitemap.
+
+func DefaultFlagSet() *flag.FlagSet {
+
+	fs := flag.NewFlagSet("wof-md2sitemap", flag.ExitOnError)
+
+	// multi-value flags are appended to so start from scratch every time
+
+	priorities = nil
+	disallow = nil
+	writers = nil
+
+	fs.StringVar(&input, "input", "index.md", "What you expect the input Markdown file to be called")
+	fs.StringVar(&output, "output", "sitemap.xml", "The filename of your sitemap. If there are more than -max-urls URLs this will be a sitemap index and the URLs will be written to sitemap-1.xml, sitemap-2.xml, etc.")
+	fs.IntVar(&max_urls, "max-urls", render.SitemapMaxURLs, "The maximum number of URLs in a single sitemap")
+	fs.StringVar(&site_url, "site-url", "", "The base URL of your site, used to make permalinks absolute. Required")
+	fs.Float64Var(&priority, "priority", -1.0, "The default priority for URLs. If less than 0 then no priority is assigned")
+	fs.Var(&priorities, "priority-rule", "One or more REGEXP=PRIORITY rules for assigning priorities to URLs whose (relative) path matches REGEXP. The first matching rule wins")
+	fs.BoolVar(&drafts, "drafts", false, "Include drafts (posts with \"published: false\" front matter or in a drafts directory)")
+	fs.BoolVar(&future, "future", false, "Include posts dated after the build time")
+	fs.StringVar(&build_time, "build-time", "", "The time (an RFC3339 timestamp or a YYYY-MM-DD date) that posts are considered published relative to. If empty the current time is used")
+	fs.BoolVar(&robots, "robots", false, "Also write a robots.txt file pointing to your sitemap")
+	fs.StringVar(&robots_output, "robots-output", "robots.txt", "The filename of your robots.txt file")
+	fs.Var(&disallow, "disallow", "One or more paths to disallow in your robots.txt file")
+	fs.Var(&writers, "writer", "One or more writer to output rendered Markdown to. Valid writers are: fs=PATH; null; stdout")
+
+	return fs
+}
@@ -0,0 +1,214 @@
+package md2sitemap
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/whosonfirst/go-whosonfirst-markdown/publish"
+	"github.com/whosonfirst/go-whosonfirst-markdown/render"
+	"github.com/whosonfirst/go-whosonfirst-markdown/site"
+	"github.com/whosonfirst/go-whosonfirst-markdown/writer"
+)
+
+type RobotsOptions struct {
+	Output   string
+	Disallow []string
+}
+
+func Render(ctx context.Context, path string, opts *render.SitemapOptions, robots_opts *RobotsOptions) error {
+
+	select {
+	case <-ctx.Done():
+		return nil
+	default:
+		return RenderDirectory(ctx, path, opts, robots_opts)
+	}
+}
+
+func RenderDirectory(ctx context.Context, dir string, opts *render.SitemapOptions, robots_opts *RobotsOptions) error {
+
+	abs_dir, err := filepath.Abs(dir)
+
+	if err != nil {
+		return err
+	}
+
+	urls, err := GatherURLs(ctx, abs_dir, opts)
+
+	if err != nil {
+		return err
+	}
+
+	files, err := render.RenderSitemaps(urls, opts)
+
+	if err != nil {
+		return err
+	}
+
+	w := ctx.Value("writer").(writer.Writer)
+
+	if w == nil {
+		return errors.New("Can't load writer from context")
+	}
+
+	for _, f := range files {
+
+		out_path := filepath.Join(dir, f.Path)
+		err := w.Write(out_path, f.Body)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	if robots_opts == nil {
+		return nil
+	}
+
+	sitemap_url := opts.Site.AbsoluteURL(opts.Output)
+
+	fh, err := render.RenderRobots(sitemap_url, robots_opts.Disallow)
+
+	if err != nil {
+		return err
+	}
+
+	out_path := filepath.Join(dir, robots_opts.Output)
+	return w.Write(out_path, fh)
+}
+
+// GatherURLs returns a sitemap URL for every file named opts.Input in root, sorted
+// by location. URLs are derived from permalinks or, failing that, the file's
+// directory relative to root. The last modified date is taken from the front
+// matter (last_modified, updated, etc.) or, failing that, the file's modification
+// time.
+
+func GatherURLs(ctx context.Context, root string, opts *render.SitemapOptions) ([]*render.SitemapURL, error) {
+
+	site_opts := site.DefaultSiteOptions()
+	site_opts.Input = opts.Input
+	site_opts.Filter = opts.Filter
+
+	s, err := site.LoadSite(ctx, root, site_opts)
+
+	if err != nil {
+		return nil, err
+	}
+
+	urls := make([]*render.SitemapURL, 0)
+
+	for _, doc := range s.Documents() {
+
+		fm := doc.FrontMatter
+		loc := fm.Permalink
+
+		if loc == "" {
+
+			rel_path, err := s.Relative(doc)
+
+			if err != nil {
+				return nil, err
+			}
+
+			rel_path = filepath.Dir(rel_path)
+
+			loc = "/" + filepath.ToSlash(rel_path) + "/"
+
+			if rel_path == "." {
+				loc = "/"
+			}
+		}
+
+		var lastmod *time.Time
+
+		if fm.LastModified != nil {
+			lastmod = fm.LastModified
+		} else {
+			t := s.ModTime(doc)
+			lastmod = &t
+		}
+
+		u := render.NewSitemapURL(loc, lastmod, opts)
+		urls = append(urls, u)
+	}
+
+	sort.Slice(urls, func(i, j int) bool {
+		return urls[i].Loc < urls[j].Loc
+	})
+
+	return urls, nil
+}
+
+// Run parses the command line and runs wof-md2sitemap.
+
+func Run(ctx context.Context) error {
+
+	fs := DefaultFlagSet()
+	fs.Parse(os.Args[1:])
+
+	return RunWithFlagSet(ctx, fs)
+}
+
+// RunWithFlagSet runs wof-md2sitemap with the (already parsed) flags and arguments in fs.
+
+func RunWithFlagSet(ctx context.Context, fs *flag.FlagSet) error {
+
+	if strings.TrimSpace(site_url) == "" {
+		return errors.New("Missing -site-url")
+	}
+
+	wr, err := writers.ToWriterWithContext(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	opts := render.DefaultSitemapOptions()
+	opts.Input = input
+	opts.Output = output
+	opts.MaxURLs = max_urls
+	opts.Priority = priority
+	opts.Priorities = priorities
+	opts.Site.BaseURL = site_url
+
+	filter, err := publish.NewFilterWithContext(ctx, drafts, future, build_time)
+
+	if err != nil {
+		return err
+	}
+
+	opts.Filter = filter
+
+	var robots_opts *RobotsOptions
+
+	if robots {
+
+		robots_opts = &RobotsOptions{
+			Output:   robots_output,
+			Disallow: disallow,
+		}
+	}
+
+	ctx = context.WithValue(ctx, "writer", wr)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	for _, path := range fs.Args() {
+
+		err := Render(ctx, path, opts, robots_opts)
+
+		if err != nil {
+			cancel()
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,98 @@
+package mdbuild
+
+import (
+	"flag"
+
+	"github.com/whosonfirst/go-whosonfirst-markdown/flags"
+)
+
+var index_modes flags.MultiStringFlags
+var index_keys flags.MultiStringFlags
+var feed_modes flags.MultiStringFlags
+var feed_formats flags.MultiStringFlags
+var feed_templates flags.MultiStringFlags
+var search_output string
+var cache_dir string
+var writers flags.WriterFlags
+
+// the flags of the tools that build runs, see flags.PassFlag
+
+var passed map[string]*flags.PassFlag
+
+// flags that build sets itself, for every tool or for the named tools, rather
+// than passing them on
+
+var own_flags = map[string][]string{
+	"cache":     nil,
+	"format":    nil,
+	"key":       nil,
+	"mode":      nil,
+	"watch":     nil,
+	"writer":    nil,
+	"output":    []string{"feed", "sitemap"},
+	"templates": []string{"feed"},
+}
+
+// isPassed reports whether the flag called name is passed on to tool.
+
+func isPassed(tool string, name string) bool {
+
+	owners, ok := own_flags[name]
+
+	if !ok {
+		return true
+	}
+
+	if owners == nil {
+		return false
+	}
+
+	for _, o := range owners {
+
+		if o == tool {
+			return false
+		}
+	}
+
+	return true
+}
+
+// DefaultFlagSet returns a new flag.FlagSet with all the flags for wof-mdbuild:
+// its own and every flag of wof-md2html, wof-md2idx, wof-md2feed and
+// wof-md2sitemap that it passes on to them.
+
+func DefaultFlagSet() *flag.FlagSet {
+
+	fs := flag.NewFlagSet("wof-mdbuild", flag.ExitOnError)
+
+	// multi-value flags are appended to so start from scratch every time
+
+	index_modes = nil
+	index_keys = nil
+	feed_modes = nil
+	feed_formats = nil
+	feed_templates = nil
+	writers = nil
+
+	passed = make(map[string]*flags.PassFlag)
+
+	fs.Var(&index_modes, "index", "One or more wof-md2idx modes to render index pages for. If empty the authors, category, date, series and tags modes are rendered, and places if -places-data is set")
+	fs.Var(&index_keys, "index-key", "One or more front matter keys to render index pages for, using the wof-md2idx key mode")
+	fs.Var(&feed_modes, "feed-mode", "One or more wof-md2feed modes to write feeds for. If empty the all mode is written")
+	fs.Var(&feed_formats, "feed-format", "One or more formats to write each feed in. If empty rss_20 is used")
+	fs.Var(&feed_templates, "feed-templates", "One or more directories containing (Go) templates to parse for feeds (the wof-md2feed -templates flag)")
+	fs.StringVar(&search_output, "search-output", "search.json", "The filename of the JSON search index written to the root of each directory. If empty no search index is written")
+	fs.StringVar(&cache_dir, "cache", "", "The path to a directory for the build manifests used to skip pages (and feeds) whose posts haven't changed since the last build. Each step has its own manifest. If empty everything is built")
+	fs.Var(&writers, "writer", "One or more writer to output rendered Markdown to. Valid writers are: fs=PATH; null; stdout")
+
+	for _, t := range build_tools {
+
+		tool_name := t.Name
+
+		flags.AddPassFlags(fs, t.FlagSet(), func(name string) bool {
+			return isPassed(tool_name, name)
+		}, passed)
+	}
+
+	return fs
+}
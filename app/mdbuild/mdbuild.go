@@ -0,0 +1,366 @@
+// Package mdbuild builds an entire site in one go: post pages, index pages,
+// feeds, a sitemap and a search index. Each directory is crawled, and each post
+// parsed, once and the resulting site.Site is shared by wof-md2html, wof-md2idx,
+// wof-md2feed and wof-md2sitemap, which are run in turn with the same flags.
+package mdbuild
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/whosonfirst/go-whosonfirst-markdown/app/md2feed"
+	"github.com/whosonfirst/go-whosonfirst-markdown/app/md2html"
+	"github.com/whosonfirst/go-whosonfirst-markdown/app/md2idx"
+	"github.com/whosonfirst/go-whosonfirst-markdown/app/md2sitemap"
+	"github.com/whosonfirst/go-whosonfirst-markdown/cache"
+	"github.com/whosonfirst/go-whosonfirst-markdown/publish"
+	"github.com/whosonfirst/go-whosonfirst-markdown/search"
+	"github.com/whosonfirst/go-whosonfirst-markdown/site"
+	"github.com/whosonfirst/go-whosonfirst-markdown/writer"
+)
+
+// Tool is one of the tools that build runs.
+
+type Tool struct {
+	Name    string
+	FlagSet func() *flag.FlagSet
+	Run     func(context.Context, *flag.FlagSet) error
+}
+
+var build_tools = []*Tool{
+	&Tool{Name: "html", FlagSet: md2html.DefaultFlagSet, Run: md2html.RunWithFlagSet},
+	&Tool{Name: "index", FlagSet: md2idx.DefaultFlagSet, Run: md2idx.RunWithFlagSet},
+	&Tool{Name: "feed", FlagSet: md2feed.DefaultFlagSet, Run: md2feed.RunWithFlagSet},
+	&Tool{Name: "sitemap", FlagSet: md2sitemap.DefaultFlagSet, Run: md2sitemap.RunWithFlagSet},
+}
+
+var default_index_modes = []string{"authors", "category", "date", "series", "tags"}
+
+// Step is the outcome of one step of a build, for example rendering the tags
+// index pages.
+
+type Step struct {
+	Name     string
+	Paths    []string
+	Duration time.Duration
+	Err      error
+}
+
+func (s *Step) String() string {
+
+	if s.Err != nil {
+		return fmt.Sprintf("%-28s FAILED, %v", s.Name, s.Err)
+	}
+
+	return fmt.Sprintf("%-28s %5d files %12v", s.Name, len(s.Paths), s.Duration)
+}
+
+// BuildDirectory builds everything for the site in dir, crawling it once, and
+// returns the outcome of each step. Steps are run in order (post pages, index
+// pages, feeds, the sitemap and then the search index) and a step that fails
+// doesn't stop the ones after it. An error is only returned if dir can not be
+// crawled.
+
+func BuildDirectory(ctx context.Context, dir string) (*site.Site, []*Step, error) {
+
+	drafts, err := strconv.ParseBool(passed["drafts"].Value("false"))
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	future, err := strconv.ParseBool(passed["future"].Value("false"))
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	filter, err := publish.NewFilterWithContext(ctx, drafts, future, passed["build-time"].Value(""))
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	site_opts := site.DefaultSiteOptions()
+	site_opts.Input = passed["input"].Value("index.md")
+	site_opts.Body = true
+	site_opts.Filter = filter
+
+	s, err := site.NewSite(ctx, dir, site_opts)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	wr, err := writers.ToWriterWithContext(ctx)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ctx = context.WithValue(ctx, "site", s)
+
+	steps := make([]*Step, 0)
+
+	run := func(name string, fn func(context.Context) error) {
+
+		select {
+		case <-ctx.Done():
+			return
+		default:
+			// pass
+		}
+
+		rec := cache.NewRecorder(wr)
+		step_ctx := context.WithValue(ctx, "writer", rec)
+
+		t1 := time.Now()
+		err := fn(step_ctx)
+
+		steps = append(steps, &Step{
+			Name:     name,
+			Paths:    rec.Paths(),
+			Duration: time.Since(t1),
+			Err:      err,
+		})
+	}
+
+	build := func(name string, t *Tool, extra map[string][]string) {
+
+		if cache_dir != "" && t.Name != "sitemap" {
+			manifest := strings.Replace(name, " ", "-", -1) + ".json"
+			extra["cache"] = []string{filepath.Join(cache_dir, manifest)}
+		}
+
+		run(name, func(step_ctx context.Context) error {
+			return runTool(step_ctx, t, dir, extra)
+		})
+	}
+
+	build("html", build_tools[0], map[string][]string{
+		"mode": []string{"directory"},
+	})
+
+	modes := []string(index_modes)
+
+	if len(modes) == 0 {
+
+		modes = append([]string{}, default_index_modes...)
+
+		if passed["places-data"].Value("") != "" {
+			modes = append(modes, "places")
+		}
+	}
+
+	for _, m := range modes {
+
+		build(fmt.Sprintf("index %s", m), build_tools[1], map[string][]string{
+			"mode": []string{m},
+		})
+	}
+
+	for _, k := range index_keys {
+
+		build(fmt.Sprintf("index key %s", k), build_tools[1], map[string][]string{
+			"mode": []string{"key"},
+			"key":  []string{k},
+		})
+	}
+
+	modes = []string(feed_modes)
+
+	if len(modes) == 0 {
+		modes = []string{"all"}
+	}
+
+	formats := []string(feed_formats)
+
+	if len(formats) == 0 {
+		formats = []string{"rss_20"}
+	}
+
+	for _, m := range modes {
+
+		for _, f := range formats {
+
+			build(fmt.Sprintf("feed %s %s", m, f), build_tools[2], map[string][]string{
+				"mode":      []string{m},
+				"format":    []string{f},
+				"templates": []string(feed_templates),
+			})
+		}
+	}
+
+	build("sitemap", build_tools[3], map[string][]string{})
+
+	if search_output != "" {
+
+		run("search", func(step_ctx context.Context) error {
+			wr := step_ctx.Value("writer").(writer.Writer)
+			return WriteSearchIndex(step_ctx, wr, s, filepath.Join(dir, search_output))
+		})
+	}
+
+	return s, steps, nil
+}
+
+// WriteSearchIndex writes a JSON search index (see search.JSONIndexer) of every
+// published document in s to path using wr.
+
+func WriteSearchIndex(ctx context.Context, wr writer.Writer, s *site.Site, path string) error {
+
+	json_idx, err := search.NewJSONIndexer()
+
+	if err != nil {
+		return err
+	}
+
+	defer json_idx.Close()
+
+	// the site is already filtered but the search index shouldn't depend on
+	// how it was crawled
+
+	idx := search.NewPublishedIndexer(json_idx, s.Filter(), s.Root)
+
+	for _, doc := range s.Documents() {
+
+		_, err := idx.IndexDocument(doc)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	fh, err := json_idx.Reader()
+
+	if err != nil {
+		return err
+	}
+
+	return wr.Write(path, fh)
+}
+
+// runTool runs t for dir with the flags passed to build and those in extra.
+
+func runTool(ctx context.Context, t *Tool, dir string, extra map[string][]string) error {
+
+	fs := t.FlagSet()
+
+	for name, fl := range passed {
+
+		if fs.Lookup(name) == nil || !isPassed(t.Name, name) {
+			continue
+		}
+
+		for _, v := range fl.Values() {
+
+			err := fs.Set(name, v)
+
+			if err != nil {
+				return fmt.Errorf("Invalid -%s flag, %v", name, err)
+			}
+		}
+	}
+
+	for name, values := range extra {
+
+		for _, v := range values {
+
+			err := fs.Set(name, v)
+
+			if err != nil {
+				return fmt.Errorf("Invalid -%s flag, %v", name, err)
+			}
+		}
+	}
+
+	// "--" so that dir is never mistaken for a flag
+
+	err := fs.Parse([]string{"--", dir})
+
+	if err != nil {
+		return err
+	}
+
+	return t.Run(ctx, fs)
+}
+
+func Run(ctx context.Context) error {
+
+	fs := DefaultFlagSet()
+	fs.Parse(os.Args[1:])
+
+	return RunWithFlagSet(ctx, fs)
+}
+
+// RunWithFlagSet runs wof-mdbuild with the (already parsed) flags and arguments in fs.
+
+func RunWithFlagSet(ctx context.Context, fs *flag.FlagSet) error {
+
+	if strings.TrimSpace(passed["site-url"].Value("")) == "" {
+		return errors.New("Missing -site-url")
+	}
+
+	// every tool has to agree on what is published, otherwise they would each
+	// crawl the site again (see site.SiteFromContext)
+
+	ctx = context.WithValue(ctx, "build_time", time.Now())
+
+	if cache_dir != "" {
+
+		err := os.MkdirAll(cache_dir, 0755)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	t1 := time.Now()
+
+	count := 0
+	failed := 0
+	total := 0
+
+	for _, path := range fs.Args() {
+
+		t2 := time.Now()
+
+		s, steps, err := BuildDirectory(ctx, path)
+
+		if err != nil {
+			fmt.Printf("Failed to build %s, %v\n", path, err)
+			total += 1
+			failed += 1
+			continue
+		}
+
+		fmt.Printf("Built %s (%d posts) in %v\n", path, len(s.Documents()), time.Since(t2))
+
+		for _, step := range steps {
+
+			fmt.Printf("  %s\n", step)
+
+			total += 1
+			count += len(step.Paths)
+
+			if step.Err != nil {
+				failed += 1
+			}
+		}
+	}
+
+	fmt.Printf("Wrote %d files in %v\n", count, time.Since(t1))
+
+	if failed > 0 {
+		return errors.New(fmt.Sprintf("%d of %d steps failed", failed, total))
+	}
+
+	return nil
+}
@@ -0,0 +1,24 @@
+package mdlint
+
+import (
+	"flag"
+)
+
+var input string
+var output string
+var authors_data string
+var taxonomies string
+
+// DefaultFlagSet returns a new flag.FlagSet with all the flags for wof-mdlint.
+
+func DefaultFlagSet() *flag.FlagSet {
+
+	fs := flag.NewFlagSet("wof-mdlint", flag.ExitOnError)
+
+	fs.StringVar(&input, "input", "index.md", "What you expect the input Markdown file to be called")
+	fs.StringVar(&output, "output", "index.html", "What you expect the output HTML file to be called")
+	fs.StringVar(&authors_data, "authors-data", "", "The path to a directory containing author profiles (JSON or Markdown files named for each author). If set every author must have a profile")
+	fs.StringVar(&taxonomies, "taxonomy", "", "The path to a JSON file defining aliases, canonical names, descriptions and slugs for authors, categories, tags (and other front matter keys). If set every author, category and tag must be defined in the corresponding taxonomy, if there is one")
+
+	return fs
+}
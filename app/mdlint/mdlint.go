@@ -0,0 +1,77 @@
+package mdlint
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/whosonfirst/go-whosonfirst-markdown/authors"
+	"github.com/whosonfirst/go-whosonfirst-markdown/lint"
+	"github.com/whosonfirst/go-whosonfirst-markdown/taxonomy"
+)
+
+// Run parses the command line and runs wof-mdlint.
+
+func Run(ctx context.Context) error {
+
+	fs := DefaultFlagSet()
+	fs.Parse(os.Args[1:])
+
+	return RunWithFlagSet(ctx, fs)
+}
+
+// RunWithFlagSet runs wof-mdlint with the (already parsed) flags and arguments in fs.
+// Problems are printed, one per line, and reported as an error.
+
+func RunWithFlagSet(ctx context.Context, fs *flag.FlagSet) error {
+
+	opts := lint.DefaultLintOptions()
+	opts.Input = input
+	opts.Output = output
+
+	if authors_data != "" {
+
+		p, err := authors.NewProfilesFromDirectory(authors_data)
+
+		if err != nil {
+			return err
+		}
+
+		opts.Authors = p
+	}
+
+	if taxonomies != "" {
+
+		t, err := taxonomy.NewTaxonomiesFromFile(taxonomies)
+
+		if err != nil {
+			return err
+		}
+
+		opts.Taxonomies = t
+	}
+
+	count := 0
+
+	for _, path := range fs.Args() {
+
+		problems, err := lint.LintDirectory(ctx, path, opts)
+
+		if err != nil {
+			return err
+		}
+
+		for _, p := range problems {
+			fmt.Println(p)
+		}
+
+		count += len(problems)
+	}
+
+	if count > 0 {
+		return fmt.Errorf("Found %d problems", count)
+	}
+
+	return nil
+}
@@ -0,0 +1,22 @@
+package mdparse
+
+import (
+	"flag"
+)
+
+var frontmatter bool
+var body bool
+var all bool
+
+// DefaultFlagSet returns a new flag.FlagSet with all the flags for wof-mdparse.
+
+func DefaultFlagSet() *flag.FlagSet {
+
+	fs := flag.NewFlagSet("wof-mdparse", flag.ExitOnError)
+
+	fs.BoolVar(&frontmatter, "frontmatter", false, "Dump (Jekyll) frontmatter")
+	fs.BoolVar(&body, "body", false, "Dump (Markdown) body")
+	fs.BoolVar(&all, "all", false, "Dump both frontmatter and body")
+
+	return fs
+}
@@ -0,0 +1,54 @@
+package mdparse
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/whosonfirst/go-whosonfirst-markdown/parser"
+)
+
+// Run parses the command line and runs wof-mdparse.
+
+func Run(ctx context.Context) error {
+
+	fs := DefaultFlagSet()
+	fs.Parse(os.Args[1:])
+
+	return RunWithFlagSet(ctx, fs)
+}
+
+// RunWithFlagSet runs wof-mdparse with the (already parsed) flags and arguments in fs.
+
+func RunWithFlagSet(ctx context.Context, fs *flag.FlagSet) error {
+
+	if all {
+		frontmatter = true
+		body = true
+	}
+
+	opts := parser.DefaultParseOptions()
+	opts.FrontMatter = frontmatter
+	opts.Body = body
+
+	for _, path := range fs.Args() {
+
+		fm, b, err := parser.ParseFile(path, opts)
+
+		if err != nil {
+			return err
+		}
+
+		if frontmatter {
+			fmt.Println(fm.String())
+		}
+
+		if body {
+			fmt.Println(b.String())
+		}
+
+	}
+
+	return nil
+}
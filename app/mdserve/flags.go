@@ -0,0 +1,98 @@
+package mdserve
+
+import (
+	"flag"
+
+	"github.com/whosonfirst/go-whosonfirst-markdown/app/md2feed"
+	"github.com/whosonfirst/go-whosonfirst-markdown/app/md2idx"
+	"github.com/whosonfirst/go-whosonfirst-markdown/flags"
+)
+
+var host string
+var port int
+var input string
+var output string
+var header string
+var footer string
+var related int
+var drafts bool
+var future bool
+var build_time string
+var authors_data string
+var generated string
+var generate bool
+var index_modes flags.MultiStringFlags
+var index_keys flags.MultiStringFlags
+var feed_modes flags.MultiStringFlags
+var feed_formats flags.MultiStringFlags
+var feed_templates flags.MultiStringFlags
+var live_reload bool
+var templates flags.HTMLTemplateFlags
+
+// the other flags of wof-md2idx and wof-md2feed, passed on to them by Generate
+
+var passed map[string]*flags.PassFlag
+
+// flags of wof-md2idx and wof-md2feed that are never passed on because Generate
+// sets them itself
+
+var own_flags = map[string]bool{
+	"cache":  true,
+	"format": true,
+	"key":    true,
+	"mode":   true,
+	"watch":  true,
+	"writer": true,
+}
+
+// DefaultFlagSet returns a new flag.FlagSet with all the flags for wof-md-serve.
+
+func DefaultFlagSet() *flag.FlagSet {
+
+	fs := flag.NewFlagSet("wof-md-serve", flag.ExitOnError)
+
+	// multi-value flags are appended to so start from scratch every time
+
+	templates = nil
+	index_modes = nil
+	index_keys = nil
+	feed_modes = nil
+	feed_formats = nil
+	feed_templates = nil
+
+	passed = make(map[string]*flags.PassFlag)
+
+	fs.StringVar(&host, "host", "localhost", "The host to listen on")
+	fs.IntVar(&port, "port", 8080, "The port to listen on")
+	fs.StringVar(&input, "input", "index.md", "What you expect the input Markdown file to be called")
+	fs.StringVar(&output, "output", "index.html", "What you expect the output HTML file to be called")
+	fs.StringVar(&header, "header", "", "The name of the (Go) template to use as a custom header")
+	fs.StringVar(&footer, "footer", "", "The name of the (Go) template to use as a custom footer")
+	fs.IntVar(&related, "related", 5, "The maximum number of related posts to pass to header and footer templates")
+	fs.BoolVar(&drafts, "drafts", false, "Include drafts (posts with \"published: false\" front matter or in a drafts directory)")
+	fs.BoolVar(&future, "future", false, "Include posts dated after the build time")
+	fs.StringVar(&build_time, "build-time", "", "The time (an RFC3339 timestamp or a YYYY-MM-DD date) that posts are considered published relative to. If empty the current time is used")
+	fs.StringVar(&authors_data, "authors-data", "", "The path to a directory containing author profiles (JSON or Markdown files named for each author) to pass to header and footer templates")
+	fs.StringVar(&generated, "generated", "", "The path to a directory containing other generated files to serve alongside posts. Index pages and feeds rendered by -generate take precedence over files in this directory")
+	fs.BoolVar(&generate, "generate", true, "Render index pages (using wof-md2idx) and feeds (using wof-md2feed), in memory, every time the site is loaded")
+	fs.Var(&index_modes, "index", "One or more wof-md2idx modes to render index pages for. If empty the authors, category, date, series and tags modes are rendered, and places if -places-data is set")
+	fs.Var(&index_keys, "index-key", "One or more front matter keys to render index pages for, using the wof-md2idx key mode")
+	fs.Var(&feed_modes, "feed-mode", "One or more wof-md2feed modes to render feeds for. If empty the all mode is rendered")
+	fs.Var(&feed_formats, "feed-format", "One or more formats to render each feed in. If empty rss_20 is used")
+	fs.Var(&feed_templates, "feed-templates", "One or more directories containing (Go) templates to parse for feeds (the wof-md2feed -templates flag)")
+	fs.BoolVar(&live_reload, "live-reload", true, "Reload pages in the browser when posts, templates, author profiles, the taxonomy or generated files change")
+	fs.Var(&templates, "templates", "One or more directories containing (Go) templates to parse")
+
+	// every other flag of wof-md2idx and wof-md2feed, for example -list,
+	// -rollup or -site-title, so that generated pages and feeds are the same
+	// as those of a production build
+
+	pass := func(name string) bool {
+		return !own_flags[name]
+	}
+
+	flags.AddPassFlags(fs, md2idx.DefaultFlagSet(), pass, passed)
+	flags.AddPassFlags(fs, md2feed.DefaultFlagSet(), pass, passed)
+
+	return fs
+}
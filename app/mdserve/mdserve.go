@@ -0,0 +1,725 @@
+package mdserve
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/whosonfirst/go-whosonfirst-markdown/app/md2feed"
+	"github.com/whosonfirst/go-whosonfirst-markdown/app/md2idx"
+	"github.com/whosonfirst/go-whosonfirst-markdown/authors"
+	"github.com/whosonfirst/go-whosonfirst-markdown/flags"
+	"github.com/whosonfirst/go-whosonfirst-markdown/nav"
+	"github.com/whosonfirst/go-whosonfirst-markdown/publish"
+	"github.com/whosonfirst/go-whosonfirst-markdown/render"
+	"github.com/whosonfirst/go-whosonfirst-markdown/site"
+	"github.com/whosonfirst/go-whosonfirst-markdown/watch"
+	"github.com/whosonfirst/go-whosonfirst-markdown/writer"
+)
+
+// the path that browsers listen to for reload events
+
+const live_reload_path = "/_livereload"
+
+const live_reload_script = `<script>
+(function(){
+	var es = new EventSource("` + live_reload_path + `");
+	es.onmessage = function(){ location.reload(); };
+})();
+</script>
+`
+
+// Site is everything needed to render the posts in root on request. It is
+// replaced, as a whole, whenever anything changes.
+
+type Site struct {
+	root       string
+	opts       *render.HTMLOptions
+	navigation *nav.Navigation
+	// output paths (relative to root, as written by wof-md2html) to the
+	// (absolute) path of the Markdown file they are rendered from
+	pages map[string]string
+	// index pages and feeds rendered by Generate, keyed by their path
+	// relative to root
+	files map[string][]byte
+	site  *site.Site
+}
+
+// GenerateOptions are the runs of wof-md2idx (Index) and wof-md2feed (Feeds)
+// that Generate makes to render index pages and feeds.
+
+type GenerateOptions struct {
+	Index []*GenerateRun
+	Feeds []*GenerateRun
+}
+
+// GenerateRun is a single run of wof-md2idx or wof-md2feed: a name, for logging,
+// and the command line arguments (but not the content root) to run it with.
+
+type GenerateRun struct {
+	Name string
+	Args []string
+}
+
+// LoadSite parses templates and author profiles and gathers the front matter of
+// every post in root, exactly as wof-md2html does in directory mode.
+
+func LoadSite(ctx context.Context, root string, opts render.HTMLOptions, templates flags.HTMLTemplateFlags, authors_data string, nav_opts *nav.NavigationOptions) (*Site, error) {
+
+	t, err := templates.Parse()
+
+	if err != nil {
+		return nil, err
+	}
+
+	opts.Templates = t
+
+	if authors_data != "" {
+
+		p, err := authors.NewProfilesFromDirectory(authors_data)
+
+		if err != nil {
+			return nil, err
+		}
+
+		opts.Authors = p
+	}
+
+	site_opts := site.DefaultSiteOptions()
+	site_opts.Input = opts.Input
+	site_opts.Filter = opts.Filter
+
+	s, err := site.NewSite(ctx, root, site_opts)
+
+	if err != nil {
+		return nil, err
+	}
+
+	n, err := nav.NewNavigation(s.Documents(), nav_opts)
+
+	if err != nil {
+		return nil, err
+	}
+
+	pages := make(map[string]string)
+
+	for _, doc := range s.Documents() {
+		out_path := render.HTMLOutputPath(doc.FrontMatter, doc.Path, root, &opts)
+		pages[filepath.ToSlash(out_path)] = doc.Path
+	}
+
+	ps := Site{
+		root:       root,
+		opts:       &opts,
+		navigation: n,
+		pages:      pages,
+		files:      make(map[string][]byte),
+		site:       s,
+	}
+
+	return &ps, nil
+}
+
+// Generate renders index pages and feeds for the site, in memory, by running
+// wof-md2idx and wof-md2feed with the site that LoadSite has already crawled. A
+// run that fails is logged and doesn't stop the others.
+
+func (ps *Site) Generate(ctx context.Context, gen_opts *GenerateOptions) {
+
+	wr := newMemoryWriter()
+
+	ctx = context.WithValue(ctx, "writer", wr)
+	ctx = context.WithValue(ctx, "site", ps.site)
+
+	// so that the tools agree with the site about what is published (see
+	// site.SiteFromContext)
+
+	ctx = context.WithValue(ctx, "build_time", ps.opts.Filter.BuildTime)
+
+	run := func(r *GenerateRun, fs *flag.FlagSet, fn func(context.Context, *flag.FlagSet) error) {
+
+		// "--" so that root is never mistaken for a flag
+
+		args := append(append([]string{}, r.Args...), "--", ps.root)
+
+		err := fs.Parse(args)
+
+		if err == nil {
+			err = fn(ctx, fs)
+		}
+
+		if err != nil {
+			log.Printf("Failed to render %s, %v\n", r.Name, err)
+		}
+	}
+
+	for _, r := range gen_opts.Index {
+		run(r, md2idx.DefaultFlagSet(), md2idx.RunWithFlagSet)
+	}
+
+	for _, r := range gen_opts.Feeds {
+		run(r, md2feed.DefaultFlagSet(), md2feed.RunWithFlagSet)
+	}
+
+	for abs_path, body := range wr.files {
+
+		rel_path, err := filepath.Rel(ps.root, abs_path)
+
+		if err != nil || strings.HasPrefix(rel_path, "..") {
+			continue
+		}
+
+		ps.files["/"+filepath.ToSlash(rel_path)] = body
+	}
+}
+
+// memoryWriter is a writer.Writer that keeps everything written to it in memory.
+
+type memoryWriter struct {
+	writer.Writer
+	mu    *sync.Mutex
+	files map[string][]byte
+}
+
+func newMemoryWriter() *memoryWriter {
+
+	w := memoryWriter{
+		mu:    new(sync.Mutex),
+		files: make(map[string][]byte),
+	}
+
+	return &w
+}
+
+func (w *memoryWriter) Write(path string, fh io.ReadCloser) error {
+
+	defer fh.Close()
+
+	body, err := io.ReadAll(fh)
+
+	if err != nil {
+		return err
+	}
+
+	w.mu.Lock()
+	w.files[path] = body
+	w.mu.Unlock()
+
+	return nil
+}
+
+// RenderPage renders the post at abs_path, the same way wof-md2html does.
+
+func (ps *Site) RenderPage(abs_path string) ([]byte, error) {
+
+	site_opts := site.DefaultSiteOptions()
+	site_opts.Input = ps.opts.Input
+	site_opts.Body = true
+
+	doc, err := site.ParseDocument(abs_path, site_opts)
+
+	if err != nil {
+		return nil, err
+	}
+
+	page_ctx := render.NewPageContext(doc, ps.opts)
+	page_ctx.SetNavigation(ps.navigation.Context(abs_path))
+
+	fh, err := render.RenderHTMLWithContext(doc, ps.opts, page_ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer fh.Close()
+
+	return io.ReadAll(fh)
+}
+
+// Reloader sends a reload event to every connected browser when Reload is called.
+
+type Reloader struct {
+	mu      *sync.Mutex
+	clients map[chan bool]bool
+}
+
+func NewReloader() *Reloader {
+
+	r := Reloader{
+		mu:      new(sync.Mutex),
+		clients: make(map[chan bool]bool),
+	}
+
+	return &r
+}
+
+func (r *Reloader) Reload() {
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for ch, _ := range r.clients {
+
+		select {
+		case ch <- true:
+			// pass
+		default:
+			// already has a reload pending
+		}
+	}
+}
+
+// ServeHTTP sends (server-sent) events to a browser until it disconnects.
+
+func (r *Reloader) ServeHTTP(rsp http.ResponseWriter, req *http.Request) {
+
+	flusher, ok := rsp.(http.Flusher)
+
+	if !ok {
+		http.Error(rsp, "Streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	ch := make(chan bool, 1)
+
+	r.mu.Lock()
+	r.clients[ch] = true
+	r.mu.Unlock()
+
+	defer func() {
+		r.mu.Lock()
+		delete(r.clients, ch)
+		r.mu.Unlock()
+	}()
+
+	rsp.Header().Set("Content-Type", "text/event-stream")
+	rsp.Header().Set("Cache-Control", "no-cache")
+	flusher.Flush()
+
+	for {
+		select {
+		case <-req.Context().Done():
+			return
+		case <-ch:
+			fmt.Fprint(rsp, "data: reload\n\n")
+			flusher.Flush()
+		}
+	}
+}
+
+// InjectScript inserts script before the closing body tag of an HTML document or
+// at the end of the document if there isn't one.
+
+func InjectScript(body []byte, script string) []byte {
+
+	idx := bytes.LastIndex(bytes.ToLower(body), []byte("</body>"))
+
+	if idx == -1 {
+		return append(body, []byte(script)...)
+	}
+
+	out := make([]byte, 0, len(body)+len(script))
+	out = append(out, body[:idx]...)
+	out = append(out, []byte(script)...)
+	out = append(out, body[idx:]...)
+
+	return out
+}
+
+type Server struct {
+	site        *Site
+	mu          *sync.RWMutex
+	generated   string
+	live_reload bool
+}
+
+func (s *Server) SetSite(ps *Site) {
+	s.mu.Lock()
+	s.site = ps
+	s.mu.Unlock()
+}
+
+// ServeHTTP serves posts, rendered on request, then the index pages and feeds
+// rendered by Generate, then files in the generated directory and finally static
+// files in the content root.
+
+func (s *Server) ServeHTTP(rsp http.ResponseWriter, req *http.Request) {
+
+	s.mu.RLock()
+	ps := s.site
+	s.mu.RUnlock()
+
+	url_path := path.Clean("/" + req.URL.Path)
+
+	page_path := url_path
+
+	if strings.HasSuffix(req.URL.Path, "/") {
+		page_path = path.Join(url_path, ps.opts.Output)
+	}
+
+	abs_path, ok := ps.pages[page_path]
+
+	if ok {
+
+		body, err := ps.RenderPage(abs_path)
+
+		if err != nil {
+			log.Printf("Failed to render %s, %v\n", abs_path, err)
+			http.Error(rsp, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		s.writeHTML(rsp, body)
+		return
+	}
+
+	body, ok := ps.files[page_path]
+
+	if ok {
+
+		if path.Ext(page_path) == ".html" {
+			s.writeHTML(rsp, body)
+			return
+		}
+
+		rsp.Header().Set("Cache-Control", "no-cache")
+		http.ServeContent(rsp, req, page_path, time.Time{}, bytes.NewReader(body))
+		return
+	}
+
+	// directories without a trailing slash
+
+	if !strings.HasSuffix(req.URL.Path, "/") {
+
+		dir_path := path.Join(url_path, ps.opts.Output)
+
+		_, is_page := ps.pages[dir_path]
+		_, is_file := ps.files[dir_path]
+
+		if is_page || is_file || s.isFile(s.generated, dir_path) {
+			http.Redirect(rsp, req, url_path+"/", http.StatusMovedPermanently)
+			return
+		}
+	}
+
+	for _, root := range []string{s.generated, ps.root} {
+
+		if !s.isFile(root, page_path) {
+			continue
+		}
+
+		local_path := filepath.Join(root, filepath.FromSlash(page_path))
+
+		if filepath.Ext(local_path) != ".html" {
+			http.ServeFile(rsp, req, local_path)
+			return
+		}
+
+		body, err := os.ReadFile(local_path)
+
+		if err != nil {
+			http.Error(rsp, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		s.writeHTML(rsp, body)
+		return
+	}
+
+	http.NotFound(rsp, req)
+}
+
+func (s *Server) writeHTML(rsp http.ResponseWriter, body []byte) {
+
+	if s.live_reload {
+		body = InjectScript(body, live_reload_script)
+	}
+
+	rsp.Header().Set("Content-Type", "text/html; charset=utf-8")
+	rsp.Header().Set("Cache-Control", "no-cache")
+	rsp.Write(body)
+}
+
+func (s *Server) isFile(root string, rel_path string) bool {
+
+	if root == "" {
+		return false
+	}
+
+	info, err := os.Stat(filepath.Join(root, filepath.FromSlash(rel_path)))
+
+	if err != nil {
+		return false
+	}
+
+	return !info.IsDir()
+}
+
+// DefaultGenerateOptions returns the runs of wof-md2idx and wof-md2feed for the
+// -index, -index-key, -feed-mode and -feed-format flags, the same runs that
+// wof-mdbuild would make. Each run is passed the flags that wof-md-serve shares
+// with the tool and every other flag of the tool that was set.
+
+func DefaultGenerateOptions() *GenerateOptions {
+
+	// the flags that wof-md-serve uses itself and shares with both tools
+
+	common := []string{
+		"-input=" + input,
+		"-drafts=" + strconv.FormatBool(drafts),
+		"-future=" + strconv.FormatBool(future),
+		"-build-time=" + build_time,
+		"-authors-data=" + authors_data,
+	}
+
+	idx_args := append([]string{
+		"-output=" + output,
+		"-header=" + header,
+		"-footer=" + footer,
+	}, common...)
+
+	// templates has already been expanded to a list of files, each of which
+	// is crawled (as itself) by wof-md2idx
+
+	for _, t := range templates {
+		idx_args = append(idx_args, "-templates="+t)
+	}
+
+	idx_args = append(idx_args, passArgs(md2idx.DefaultFlagSet())...)
+
+	// feeds link to the server unless told otherwise
+
+	feed_args := append([]string{
+		fmt.Sprintf("-site-url=http://%s:%d/", host, port),
+	}, common...)
+
+	for _, t := range feed_templates {
+		feed_args = append(feed_args, "-templates="+t)
+	}
+
+	feed_args = append(feed_args, passArgs(md2feed.DefaultFlagSet())...)
+
+	gen_opts := &GenerateOptions{
+		Index: make([]*GenerateRun, 0),
+		Feeds: make([]*GenerateRun, 0),
+	}
+
+	modes := []string(index_modes)
+
+	if len(modes) == 0 {
+
+		modes = []string{"authors", "category", "date", "series", "tags"}
+
+		if passed["places-data"].Value("") != "" {
+			modes = append(modes, "places")
+		}
+	}
+
+	for _, m := range modes {
+
+		gen_opts.Index = append(gen_opts.Index, &GenerateRun{
+			Name: fmt.Sprintf("the %s index pages", m),
+			Args: append([]string{"-mode=" + m}, idx_args...),
+		})
+	}
+
+	for _, k := range index_keys {
+
+		gen_opts.Index = append(gen_opts.Index, &GenerateRun{
+			Name: fmt.Sprintf("the %s index pages", k),
+			Args: append([]string{"-mode=key", "-key=" + k}, idx_args...),
+		})
+	}
+
+	modes = []string(feed_modes)
+
+	if len(modes) == 0 {
+		modes = []string{"all"}
+	}
+
+	formats := []string(feed_formats)
+
+	if len(formats) == 0 {
+		formats = []string{"rss_20"}
+	}
+
+	for _, m := range modes {
+
+		for _, f := range formats {
+
+			gen_opts.Feeds = append(gen_opts.Feeds, &GenerateRun{
+				Name: fmt.Sprintf("the %s %s feeds", m, f),
+				Args: append([]string{"-mode=" + m, "-format=" + f}, feed_args...),
+			})
+		}
+	}
+
+	return gen_opts
+}
+
+// passArgs returns the command line arguments for the flags given to wof-md-serve
+// that are passed on to the tool whose flags are tool_fs.
+
+func passArgs(tool_fs *flag.FlagSet) []string {
+
+	names := make([]string, 0)
+
+	for name, _ := range passed {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	args := make([]string, 0)
+
+	for _, name := range names {
+
+		if tool_fs.Lookup(name) == nil {
+			continue
+		}
+
+		for _, v := range passed[name].Values() {
+			args = append(args, fmt.Sprintf("-%s=%s", name, v))
+		}
+	}
+
+	return args
+}
+
+// Run parses the command line and runs wof-md-serve.
+
+func Run(ctx context.Context) error {
+
+	fs := DefaultFlagSet()
+	fs.Parse(os.Args[1:])
+
+	return RunWithFlagSet(ctx, fs)
+}
+
+// RunWithFlagSet runs wof-md-serve with the (already parsed) flags and arguments in fs.
+
+func RunWithFlagSet(ctx context.Context, fs *flag.FlagSet) error {
+
+	if fs.NArg() != 1 {
+		return errors.New("Missing or invalid content root, expected a single directory")
+	}
+
+	root, err := filepath.Abs(fs.Arg(0))
+
+	if err != nil {
+		return err
+	}
+
+	opts := render.DefaultHTMLOptions()
+	opts.Mode = "directory"
+	opts.Input = input
+	opts.Output = output
+	opts.Header = header
+	opts.Footer = footer
+
+	filter, err := publish.NewFilter(drafts, future, build_time)
+
+	if err != nil {
+		return err
+	}
+
+	opts.Filter = filter
+
+	nav_opts := nav.DefaultNavigationOptions()
+	nav_opts.Related = related
+
+	var gen_opts *GenerateOptions
+
+	if generate {
+		gen_opts = DefaultGenerateOptions()
+	}
+
+	load := func(ctx context.Context) (*Site, error) {
+
+		ps, err := LoadSite(ctx, root, *opts, templates, authors_data, nav_opts)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if gen_opts != nil {
+			ps.Generate(ctx, gen_opts)
+		}
+
+		return ps, nil
+	}
+
+	ps, err := load(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	s := &Server{
+		site:        ps,
+		mu:          new(sync.RWMutex),
+		generated:   generated,
+		live_reload: live_reload,
+	}
+
+	reloader := NewReloader()
+
+	watch_paths := append([]string(templates), feed_templates...)
+	watch_paths = append(watch_paths, root, authors_data, passed["taxonomy"].Value(""), generated)
+
+	w, err := watch.NewWatcher(watch_paths, watch.DefaultWatcherOptions())
+
+	if err != nil {
+		return err
+	}
+
+	go func() {
+
+		err := w.Watch(ctx, func(ctx context.Context, changes []*watch.Change) error {
+
+			for _, c := range changes {
+				log.Println(c)
+			}
+
+			ps, err := load(ctx)
+
+			if err != nil {
+				return err
+			}
+
+			s.SetSite(ps)
+			reloader.Reload()
+
+			return nil
+		})
+
+		if err != nil {
+			log.Printf("Failed to watch for changes, %v\n", err)
+		}
+	}()
+
+	mux := http.NewServeMux()
+	mux.Handle("/", s)
+
+	if live_reload {
+		mux.Handle(live_reload_path, reloader)
+	}
+
+	address := fmt.Sprintf("%s:%d", host, port)
+	log.Printf("Listening on http://%s\n", address)
+
+	return http.ListenAndServe(address, mux)
+}
@@ -0,0 +1,277 @@
+package authors
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/russross/blackfriday/v2"
+	"github.com/whosonfirst/go-whosonfirst-markdown/parser"
+	"github.com/whosonfirst/go-whosonfirst-markdown/places"
+	"github.com/whosonfirst/go-whosonfirst-markdown/uri"
+)
+
+type Link struct {
+	Title string `json:"title,omitempty"`
+	URL   string `json:"url"`
+}
+
+// Profile describes an author. Id is the author's name pruned using uri.PruneString
+// and Bio is Markdown. LocalityId is the Who's On First ID of the author's home
+// locality and Locality is only defined once the profiles have been passed to
+// Profiles.ResolvePlaces.
+
+type Profile struct {
+	Id         string        `json:"-"`
+	Name       string        `json:"name"`
+	Bio        string        `json:"bio,omitempty"`
+	Avatar     string        `json:"avatar,omitempty"`
+	URL        string        `json:"url,omitempty"`
+	Email      string        `json:"email,omitempty"`
+	Links      []*Link       `json:"links,omitempty"`
+	LocalityId int64         `json:"locality,omitempty"`
+	Locality   *places.Place `json:"-"`
+}
+
+// BioHTML returns the author's bio rendered as HTML
+
+func (p *Profile) BioHTML() template.HTML {
+	html := blackfriday.Run([]byte(p.Bio))
+	return template.HTML(html)
+}
+
+type Profiles struct {
+	profiles map[string]*Profile
+}
+
+// NewProfilesFromDirectory reads author profiles from root. Profiles are either
+// JSON files or Markdown files with front matter named for the author, pruned
+// using uri.PruneString, for example aaronstraupcope.json or aaronstraupcope.md.
+// In Markdown files the body of the document is the author's bio and the
+// following front matter keys are used: name (or title), avatar (or image), url,
+// email, links (a list of URLs) and locality.
+
+func NewProfilesFromDirectory(root string) (*Profiles, error) {
+
+	abs_root, err := filepath.Abs(root)
+
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := os.Stat(abs_root)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !info.IsDir() {
+		return nil, errors.New("Authors data is not a directory")
+	}
+
+	matches, err := filepath.Glob(filepath.Join(abs_root, "*"))
+
+	if err != nil {
+		return nil, err
+	}
+
+	profiles := make(map[string]*Profile)
+
+	for _, path := range matches {
+
+		var p *Profile
+		var err error
+
+		switch filepath.Ext(path) {
+		case ".json":
+			p, err = readJSON(path)
+		case ".md":
+			p, err = readMarkdown(path)
+		default:
+			continue
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to parse %s, %v", path, err)
+		}
+
+		fname := filepath.Base(path)
+		fname = strings.TrimSuffix(fname, filepath.Ext(fname))
+
+		id, err := uri.PruneString(fname)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if id == "" {
+			continue
+		}
+
+		if _, ok := profiles[id]; ok {
+			return nil, errors.New(fmt.Sprintf("More than one profile for '%s'", id))
+		}
+
+		p.Id = id
+
+		if p.Name == "" {
+			p.Name = fname
+		}
+
+		profiles[id] = p
+	}
+
+	ps := Profiles{
+		profiles: profiles,
+	}
+
+	return &ps, nil
+}
+
+// Profile returns the profile for name or nil if there isn't one. It is safe to
+// call Profile on a nil *Profiles.
+
+func (ps *Profiles) Profile(name string) *Profile {
+
+	if ps == nil {
+		return nil
+	}
+
+	id, err := uri.PruneString(name)
+
+	if err != nil {
+		return nil
+	}
+
+	return ps.profiles[id]
+}
+
+// ProfilesForNames returns the profiles for names, skipping any names that don't
+// have a profile.
+
+func (ps *Profiles) ProfilesForNames(names []string) []*Profile {
+
+	profiles := make([]*Profile, 0)
+
+	for _, n := range names {
+
+		p := ps.Profile(n)
+
+		if p != nil {
+			profiles = append(profiles, p)
+		}
+	}
+
+	return profiles
+}
+
+// ResolvePlaces assigns the Locality for each profile with a LocalityId
+
+func (ps *Profiles) ResolvePlaces(r *places.LocalResolver) error {
+
+	if ps == nil {
+		return nil
+	}
+
+	for _, p := range ps.profiles {
+
+		if p.LocalityId <= 0 {
+			continue
+		}
+
+		pl, err := r.Place(p.LocalityId)
+
+		if err != nil {
+			return fmt.Errorf("Failed to resolve locality %d for %s, %v", p.LocalityId, p.Name, err)
+		}
+
+		p.Locality = pl
+	}
+
+	return nil
+}
+
+func readJSON(path string) (*Profile, error) {
+
+	fh, err := os.Open(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer fh.Close()
+
+	var p Profile
+
+	err = json.NewDecoder(fh).Decode(&p)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &p, nil
+}
+
+func readMarkdown(path string) (*Profile, error) {
+
+	fm, body, err := parser.ParseFile(path, parser.DefaultParseOptions())
+
+	if err != nil {
+		return nil, err
+	}
+
+	value := func(k string) string {
+
+		v := fm.Values(k)
+
+		if len(v) == 0 {
+			return ""
+		}
+
+		return v[0]
+	}
+
+	p := Profile{
+		Name:   value("name"),
+		Bio:    strings.TrimSpace(body.String()),
+		Avatar: value("avatar"),
+		URL:    value("url"),
+		Email:  value("email"),
+		Links:  make([]*Link, 0),
+	}
+
+	if p.Name == "" {
+		p.Name = fm.Title
+	}
+
+	if p.Avatar == "" {
+		p.Avatar = fm.Image
+	}
+
+	for _, u := range fm.Values("links") {
+		p.Links = append(p.Links, &Link{Title: u, URL: u})
+	}
+
+	str_locality := value("locality")
+
+	if str_locality != "" {
+
+		id, err := strconv.ParseInt(str_locality, 10, 64)
+
+		if err != nil {
+			return nil, err
+		}
+
+		p.LocalityId = id
+
+	} else if len(fm.Places) > 0 {
+		p.LocalityId = fm.Places[0]
+	}
+
+	return &p, nil
+}
@@ -0,0 +1,477 @@
+// Package cache provides a build manifest for incremental builds. The manifest
+// records, for each thing that is built (a page, a set of index pages, a feed),
+// a hash of its source, of the templates and of the options it was built with
+// along with the paths that were written. Things whose hashes haven't changed
+// since the last build are skipped and the outputs of things that are no longer
+// built (because their source was deleted, for example) are removed.
+package cache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/facebookgo/atomicfile"
+	"github.com/whosonfirst/go-whosonfirst-markdown/writer"
+)
+
+type Entry struct {
+	Source   string   `json:"source"`
+	Template string   `json:"template"`
+	Options  string   `json:"options"`
+	Outputs  []string `json:"outputs"`
+}
+
+// Manifest is a set of entries, keyed by a string that is unique to each thing
+// being built. Keys should start with a prefix for the tool (and mode) doing the
+// building so that more than one tool can share the same manifest; see Prune.
+
+type Manifest struct {
+	Entries  map[string]*Entry `json:"entries"`
+	path     string
+	template string
+	options  string
+	seen     map[string]bool
+	dirty    bool
+	mu       *sync.Mutex
+}
+
+// NewManifest returns a new, empty, manifest that is only kept in memory, for
+// example for rebuilds in watch mode. See Reset for template and options.
+
+func NewManifest(template string, options string) *Manifest {
+
+	m := Manifest{
+		Entries:  make(map[string]*Entry),
+		template: template,
+		options:  options,
+		seen:     make(map[string]bool),
+		mu:       new(sync.Mutex),
+	}
+
+	return &m
+}
+
+// OpenManifest reads the manifest at path, or returns an empty manifest if path
+// does not exist yet. template and options are the hashes of the templates and
+// options for the current build, see HashPaths and HashFlags.
+
+func OpenManifest(path string, template string, options string) (*Manifest, error) {
+
+	abs_path, err := filepath.Abs(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	m := NewManifest(template, options)
+	m.path = abs_path
+
+	fh, err := os.Open(abs_path)
+
+	if os.IsNotExist(err) {
+		return m, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer fh.Close()
+
+	err = json.NewDecoder(fh).Decode(m)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse %s, %v", abs_path, err)
+	}
+
+	if m.Entries == nil {
+		m.Entries = make(map[string]*Entry)
+	}
+
+	return m, nil
+}
+
+// Reset starts a new build, with new template and options hashes, using the
+// entries from the previous build. It is safe to call Reset on a nil *Manifest.
+
+func (m *Manifest) Reset(template string, options string) {
+
+	if m == nil {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.template = template
+	m.options = options
+	m.seen = make(map[string]bool)
+}
+
+// Build calls fn unless the entry for key was built from the same source,
+// templates and options in a previous build. The paths fn writes using the
+// writer in ctx (see writer.Writer) are recorded and any paths written last time
+// but not this time are removed. It is safe to call Build on a nil *Manifest, in
+// which case fn is always called.
+
+func (m *Manifest) Build(ctx context.Context, key string, source string, fn func(context.Context) error) error {
+
+	if m == nil {
+		return fn(ctx)
+	}
+
+	e := Entry{
+		Source:   source,
+		Template: m.template,
+		Options:  m.options,
+	}
+
+	m.mu.Lock()
+
+	m.seen[key] = true
+	prev, ok := m.Entries[key]
+
+	m.mu.Unlock()
+
+	if ok && prev.Source == e.Source && prev.Template == e.Template && prev.Options == e.Options {
+		return nil
+	}
+
+	wr, ok := ctx.Value("writer").(writer.Writer)
+
+	if !ok {
+		return errors.New("Can't load writer from context")
+	}
+
+	rec := NewRecorder(wr)
+	err := fn(context.WithValue(ctx, "writer", rec))
+
+	if err != nil {
+		return err
+	}
+
+	e.Outputs = rec.Paths()
+
+	m.mu.Lock()
+	m.Entries[key] = &e
+	m.dirty = true
+	m.mu.Unlock()
+
+	if prev == nil {
+		return nil
+	}
+
+	written := make(map[string]bool)
+
+	for _, p := range e.Outputs {
+		written[p] = true
+	}
+
+	orphans := make([]string, 0)
+
+	for _, p := range prev.Outputs {
+
+		if !written[p] {
+			orphans = append(orphans, p)
+		}
+	}
+
+	return Remove(wr, orphans)
+}
+
+// Prune removes the entries whose keys start with prefix that were not built (or
+// skipped) by Build since the manifest was opened, along with their outputs. It
+// should only be called after a complete, successful build of everything under
+// prefix. It is safe to call Prune on a nil *Manifest.
+
+func (m *Manifest) Prune(ctx context.Context, prefix string) ([]string, error) {
+
+	if m == nil {
+		return nil, nil
+	}
+
+	wr, ok := ctx.Value("writer").(writer.Writer)
+
+	if !ok {
+		return nil, errors.New("Can't load writer from context")
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	pruned := make([]string, 0)
+
+	for key, e := range m.Entries {
+
+		if !strings.HasPrefix(key, prefix) || m.seen[key] {
+			continue
+		}
+
+		err := Remove(wr, e.Outputs)
+
+		if err != nil {
+			return nil, err
+		}
+
+		delete(m.Entries, key)
+		pruned = append(pruned, key)
+		m.dirty = true
+	}
+
+	sort.Strings(pruned)
+	return pruned, nil
+}
+
+// Save writes the manifest back to the path it was opened from, if anything has
+// changed. Manifests created with NewManifest are not saved. It is safe to call
+// Save on a nil *Manifest.
+
+func (m *Manifest) Save() error {
+
+	if m == nil {
+		return nil
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.path == "" || !m.dirty {
+		return nil
+	}
+
+	err := os.MkdirAll(filepath.Dir(m.path), 0755)
+
+	if err != nil {
+		return err
+	}
+
+	out, err := atomicfile.New(m.path, os.FileMode(0644))
+
+	if err != nil {
+		return err
+	}
+
+	defer out.Close()
+
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+
+	err = enc.Encode(m)
+
+	if err != nil {
+		out.Abort()
+		return err
+	}
+
+	m.dirty = false
+	return nil
+}
+
+// Remove removes paths using wr, if it implements writer.Remover. Otherwise it
+// does nothing.
+
+func Remove(wr writer.Writer, paths []string) error {
+
+	r, ok := wr.(writer.Remover)
+
+	if !ok {
+		return nil
+	}
+
+	for _, p := range paths {
+
+		err := r.Remove(p)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Recorder is a writer.Writer that records the paths written to another writer.
+
+type Recorder struct {
+	writer.Writer
+	wr    writer.Writer
+	paths []string
+	mu    *sync.Mutex
+}
+
+func NewRecorder(wr writer.Writer) *Recorder {
+
+	r := Recorder{
+		wr:    wr,
+		paths: make([]string, 0),
+		mu:    new(sync.Mutex),
+	}
+
+	return &r
+}
+
+func (r *Recorder) Write(path string, fh io.ReadCloser) error {
+
+	err := r.wr.Write(path, fh)
+
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	r.paths = append(r.paths, path)
+	r.mu.Unlock()
+
+	return nil
+}
+
+// Remove removes path using the writer being recorded, if it implements
+// writer.Remover, so that a Recorder can be used wherever that writer could.
+
+func (r *Recorder) Remove(path string) error {
+	return Remove(r.wr, []string{path})
+}
+
+// Paths returns the paths written so far, sorted.
+
+func (r *Recorder) Paths() []string {
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	paths := make([]string, len(r.paths))
+	copy(paths, r.paths)
+
+	sort.Strings(paths)
+	return paths
+}
+
+// HashBytes returns the (hex-encoded SHA-256) hash of one or more byte slices.
+
+func HashBytes(data ...[]byte) string {
+
+	h := sha256.New()
+
+	for _, d := range data {
+		writeHash(h, d)
+	}
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// HashJSON returns the hash of one or more values encoded as JSON.
+
+func HashJSON(values ...interface{}) (string, error) {
+
+	h := sha256.New()
+
+	for _, v := range values {
+
+		enc, err := json.Marshal(v)
+
+		if err != nil {
+			return "", err
+		}
+
+		writeHash(h, enc)
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// HashPaths returns the hash of the names and contents of one or more files.
+// Directories are read recursively. Empty paths are ignored.
+
+func HashPaths(paths ...string) (string, error) {
+
+	files := make([]string, 0)
+
+	for _, root := range paths {
+
+		if root == "" {
+			continue
+		}
+
+		err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+
+			if err != nil {
+				return err
+			}
+
+			if !info.IsDir() {
+				files = append(files, path)
+			}
+
+			return nil
+		})
+
+		if err != nil {
+			return "", err
+		}
+	}
+
+	sort.Strings(files)
+
+	h := sha256.New()
+
+	for _, path := range files {
+
+		body, err := os.ReadFile(path)
+
+		if err != nil {
+			return "", err
+		}
+
+		writeHash(h, []byte(path))
+		writeHash(h, body)
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// HashFlags returns the hash of the names and values of all the flags in fs
+// except those listed in exclude (for example the flag naming the manifest
+// itself).
+
+func HashFlags(fs *flag.FlagSet, exclude ...string) string {
+
+	skip := make(map[string]bool)
+
+	for _, name := range exclude {
+		skip[name] = true
+	}
+
+	h := sha256.New()
+
+	fs.VisitAll(func(f *flag.Flag) {
+
+		if skip[f.Name] {
+			return
+		}
+
+		writeHash(h, []byte(f.Name))
+		writeHash(h, []byte(f.Value.String()))
+	})
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// writeHash writes the length of data followed by data so that, for example,
+// ("ab", "c") and ("a", "bc") hash differently.
+
+func writeHash(h hash.Hash, data []byte) {
+	fmt.Fprintf(h, "%d:", len(data))
+	h.Write(data)
+}
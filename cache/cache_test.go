@@ -0,0 +1,415 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"io"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/whosonfirst/go-whosonfirst-markdown/writer"
+)
+
+// testWriter keeps the paths written to it and records the paths removed.
+
+type testWriter struct {
+	writer.Writer
+	files   map[string]bool
+	removed []string
+}
+
+func newTestWriter() *testWriter {
+
+	w := testWriter{
+		files:   make(map[string]bool),
+		removed: make([]string, 0),
+	}
+
+	return &w
+}
+
+func (w *testWriter) Write(path string, fh io.ReadCloser) error {
+	w.files[path] = true
+	return fh.Close()
+}
+
+func (w *testWriter) Remove(path string) error {
+	delete(w.files, path)
+	w.removed = append(w.removed, path)
+	return nil
+}
+
+func (w *testWriter) Paths() []string {
+
+	paths := make([]string, 0)
+
+	for p, _ := range w.files {
+		paths = append(paths, p)
+	}
+
+	sort.Strings(paths)
+	return paths
+}
+
+// writeAll returns a build function that writes paths.
+
+func writeAll(paths ...string) func(context.Context) error {
+
+	return func(ctx context.Context) error {
+
+		wr := ctx.Value("writer").(writer.Writer)
+
+		for _, p := range paths {
+
+			err := wr.Write(p, io.NopCloser(strings.NewReader(p)))
+
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+}
+
+type testBuild struct {
+	source   string
+	template string
+	options  string
+	outputs  []string
+	err      error
+}
+
+func TestManifestBuild(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		previous *testBuild
+		current  *testBuild
+		called   bool
+		outputs  []string
+		removed  []string
+		files    []string
+	}{
+		{
+			name:    "first build",
+			current: &testBuild{source: "a", outputs: []string{"a/index.html"}},
+			called:  true,
+			outputs: []string{"a/index.html"},
+			files:   []string{"a/index.html"},
+		},
+		{
+			name:     "unchanged",
+			previous: &testBuild{source: "a", template: "t", options: "o", outputs: []string{"a/index.html", "a/page/2/index.html"}},
+			current:  &testBuild{source: "a", template: "t", options: "o", outputs: []string{"a/index.html"}},
+			called:   false,
+			outputs:  []string{"a/index.html", "a/page/2/index.html"},
+			files:    []string{"a/index.html", "a/page/2/index.html"},
+		},
+		{
+			name:     "source changed",
+			previous: &testBuild{source: "a", outputs: []string{"a/index.html"}},
+			current:  &testBuild{source: "b", outputs: []string{"a/index.html"}},
+			called:   true,
+			outputs:  []string{"a/index.html"},
+			files:    []string{"a/index.html"},
+		},
+		{
+			name:     "template changed",
+			previous: &testBuild{source: "a", template: "t1", outputs: []string{"a/index.html"}},
+			current:  &testBuild{source: "a", template: "t2", outputs: []string{"a/index.html"}},
+			called:   true,
+			outputs:  []string{"a/index.html"},
+			files:    []string{"a/index.html"},
+		},
+		{
+			name:     "options changed",
+			previous: &testBuild{source: "a", options: "o1", outputs: []string{"a/index.html"}},
+			current:  &testBuild{source: "a", options: "o2", outputs: []string{"a/index.html"}},
+			called:   true,
+			outputs:  []string{"a/index.html"},
+			files:    []string{"a/index.html"},
+		},
+		{
+			name:     "orphans are removed",
+			previous: &testBuild{source: "a", outputs: []string{"a/index.html", "a/page/2/index.html", "a/page/3/index.html"}},
+			current:  &testBuild{source: "b", outputs: []string{"a/index.html", "a/page/2/index.html"}},
+			called:   true,
+			outputs:  []string{"a/index.html", "a/page/2/index.html"},
+			removed:  []string{"a/page/3/index.html"},
+			files:    []string{"a/index.html", "a/page/2/index.html"},
+		},
+		{
+			name:     "moved",
+			previous: &testBuild{source: "a", outputs: []string{"a/index.html"}},
+			current:  &testBuild{source: "b", outputs: []string{"b/index.html"}},
+			called:   true,
+			outputs:  []string{"b/index.html"},
+			removed:  []string{"a/index.html"},
+			files:    []string{"b/index.html"},
+		},
+		{
+			name:     "failed builds are not recorded",
+			previous: &testBuild{source: "a", outputs: []string{"a/index.html", "a/page/2/index.html"}},
+			current:  &testBuild{source: "b", outputs: []string{}, err: errors.New("Failed")},
+			called:   true,
+			outputs:  []string{"a/index.html", "a/page/2/index.html"},
+			files:    []string{"a/index.html", "a/page/2/index.html"},
+		},
+	}
+
+	for _, tt := range tests {
+
+		t.Run(tt.name, func(t *testing.T) {
+
+			wr := newTestWriter()
+			ctx := context.WithValue(context.Background(), "writer", writer.Writer(wr))
+
+			m := NewManifest("", "")
+
+			if tt.previous != nil {
+
+				m.Reset(tt.previous.template, tt.previous.options)
+
+				err := m.Build(ctx, "key", tt.previous.source, writeAll(tt.previous.outputs...))
+
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			m.Reset(tt.current.template, tt.current.options)
+
+			called := false
+
+			build := func(ctx context.Context) error {
+
+				called = true
+
+				err := writeAll(tt.current.outputs...)(ctx)
+
+				if err != nil {
+					return err
+				}
+
+				return tt.current.err
+			}
+
+			err := m.Build(ctx, "key", tt.current.source, build)
+
+			if err != tt.current.err {
+				t.Fatalf("Expected error %v, got %v", tt.current.err, err)
+			}
+
+			if called != tt.called {
+				t.Errorf("Expected the build function to be called: %t", tt.called)
+			}
+
+			if !reflect.DeepEqual(m.Entries["key"].Outputs, tt.outputs) {
+				t.Errorf("Expected outputs %v, got %v", tt.outputs, m.Entries["key"].Outputs)
+			}
+
+			removed := tt.removed
+
+			if removed == nil {
+				removed = []string{}
+			}
+
+			if !reflect.DeepEqual(wr.removed, removed) {
+				t.Errorf("Expected %v to be removed, got %v", removed, wr.removed)
+			}
+
+			if !reflect.DeepEqual(wr.Paths(), tt.files) {
+				t.Errorf("Expected files %v, got %v", tt.files, wr.Paths())
+			}
+		})
+	}
+}
+
+func TestManifestPrune(t *testing.T) {
+
+	tests := []struct {
+		name    string
+		built   []string
+		prefix  string
+		pruned  []string
+		entries []string
+		files   []string
+	}{
+		{
+			name:    "everything built",
+			built:   []string{"idx:tags:a", "idx:tags:b", "feed:c"},
+			prefix:  "idx:",
+			pruned:  []string{},
+			entries: []string{"feed:c", "idx:tags:a", "idx:tags:b"},
+			files:   []string{"a/index.html", "b/index.html", "c/rss.xml"},
+		},
+		{
+			name:    "orphans",
+			built:   []string{"idx:tags:a"},
+			prefix:  "idx:",
+			pruned:  []string{"idx:tags:b"},
+			entries: []string{"feed:c", "idx:tags:a"},
+			files:   []string{"a/index.html", "c/rss.xml"},
+		},
+		{
+			name:    "other prefixes are left alone",
+			built:   []string{},
+			prefix:  "idx:tags:",
+			pruned:  []string{"idx:tags:a", "idx:tags:b"},
+			entries: []string{"feed:c"},
+			files:   []string{"c/rss.xml"},
+		},
+		{
+			name:    "nothing under prefix",
+			built:   []string{},
+			prefix:  "idx:authors:",
+			pruned:  []string{},
+			entries: []string{"feed:c", "idx:tags:a", "idx:tags:b"},
+			files:   []string{"a/index.html", "b/index.html", "c/rss.xml"},
+		},
+	}
+
+	previous := map[string]string{
+		"idx:tags:a": "a/index.html",
+		"idx:tags:b": "b/index.html",
+		"feed:c":     "c/rss.xml",
+	}
+
+	for _, tt := range tests {
+
+		t.Run(tt.name, func(t *testing.T) {
+
+			wr := newTestWriter()
+			ctx := context.WithValue(context.Background(), "writer", writer.Writer(wr))
+
+			m := NewManifest("", "")
+
+			for key, path := range previous {
+
+				err := m.Build(ctx, key, "source", writeAll(path))
+
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			// the next build, in which only some things are built
+
+			m.Reset("", "")
+
+			for _, key := range tt.built {
+
+				err := m.Build(ctx, key, "source", writeAll(previous[key]))
+
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			pruned, err := m.Prune(ctx, tt.prefix)
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(pruned, tt.pruned) {
+				t.Errorf("Expected %v to be pruned, got %v", tt.pruned, pruned)
+			}
+
+			entries := make([]string, 0)
+
+			for key, _ := range m.Entries {
+				entries = append(entries, key)
+			}
+
+			sort.Strings(entries)
+
+			if !reflect.DeepEqual(entries, tt.entries) {
+				t.Errorf("Expected entries %v, got %v", tt.entries, entries)
+			}
+
+			if !reflect.DeepEqual(wr.Paths(), tt.files) {
+				t.Errorf("Expected files %v, got %v", tt.files, wr.Paths())
+			}
+		})
+	}
+}
+
+func TestManifestSave(t *testing.T) {
+
+	path := filepath.Join(t.TempDir(), "cache", "manifest.json")
+	ctx := context.WithValue(context.Background(), "writer", writer.Writer(newTestWriter()))
+
+	m, err := OpenManifest(path, "t", "o")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = m.Build(ctx, "key", "source", writeAll("a/index.html"))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = m.Save()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m, err = OpenManifest(path, "t", "o")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	called := false
+
+	err = m.Build(ctx, "key", "source", func(ctx context.Context) error {
+		called = true
+		return nil
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if called {
+		t.Error("Expected an unchanged entry to be skipped after the manifest is reopened")
+	}
+}
+
+func TestNilManifest(t *testing.T) {
+
+	var m *Manifest
+
+	ctx := context.WithValue(context.Background(), "writer", writer.Writer(newTestWriter()))
+
+	called := false
+
+	err := m.Build(ctx, "key", "source", func(ctx context.Context) error {
+		called = true
+		return nil
+	})
+
+	if err != nil || !called {
+		t.Errorf("Expected a nil manifest to always build, got %v", err)
+	}
+
+	pruned, err := m.Prune(ctx, "")
+
+	if err != nil || len(pruned) != 0 {
+		t.Errorf("Expected a nil manifest to prune nothing, got %v %v", pruned, err)
+	}
+
+	err = m.Save()
+
+	if err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,18 @@
+package main
+
+import (
+	"context"
+	"log"
+
+	"github.com/whosonfirst/go-whosonfirst-markdown/app/mdserve"
+)
+
+func main() {
+
+	ctx := context.Background()
+	err := mdserve.Run(ctx)
+
+	if err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+
+	"github.com/whosonfirst/go-whosonfirst-markdown/app/md2feed"
+	"github.com/whosonfirst/go-whosonfirst-markdown/app/md2html"
+	"github.com/whosonfirst/go-whosonfirst-markdown/app/md2idx"
+	"github.com/whosonfirst/go-whosonfirst-markdown/app/md2sitemap"
+	"github.com/whosonfirst/go-whosonfirst-markdown/app/mdbuild"
+	"github.com/whosonfirst/go-whosonfirst-markdown/app/mdlint"
+	"github.com/whosonfirst/go-whosonfirst-markdown/app/mdparse"
+	"github.com/whosonfirst/go-whosonfirst-markdown/app/mdserve"
+	"github.com/whosonfirst/go-whosonfirst-markdown/config"
+)
+
+// the configuration file to use, if it exists, when there is no -config flag
+
+const default_config = "wof-md.json"
+
+type Command struct {
+	Description string
+	FlagSet     func() *flag.FlagSet
+	Run         func(context.Context, *flag.FlagSet) error
+}
+
+var commands = map[string]*Command{
+	"build": &Command{
+		Description: "Build the whole site, crawling it once: posts, index pages, feeds, a sitemap and a search index (wof-mdbuild)",
+		FlagSet:     mdbuild.DefaultFlagSet,
+		Run:         mdbuild.RunWithFlagSet,
+	},
+	"feed": &Command{
+		Description: "Produce RSS, Atom, JSON and GeoJSON feeds (wof-md2feed)",
+		FlagSet:     md2feed.DefaultFlagSet,
+		Run:         md2feed.RunWithFlagSet,
+	},
+	"html": &Command{
+		Description: "Render posts as HTML (wof-md2html)",
+		FlagSet:     md2html.DefaultFlagSet,
+		Run:         md2html.RunWithFlagSet,
+	},
+	"index": &Command{
+		Description: "Render index pages for dates, tags, authors and so on (wof-md2idx)",
+		FlagSet:     md2idx.DefaultFlagSet,
+		Run:         md2idx.RunWithFlagSet,
+	},
+	"lint": &Command{
+		Description: "Check posts for problems (wof-mdlint)",
+		FlagSet:     mdlint.DefaultFlagSet,
+		Run:         mdlint.RunWithFlagSet,
+	},
+	"parse": &Command{
+		Description: "Dump the front matter and body of one or more Markdown files (wof-mdparse)",
+		FlagSet:     mdparse.DefaultFlagSet,
+		Run:         mdparse.RunWithFlagSet,
+	},
+	"serve": &Command{
+		Description: "Preview a site locally, with live reload (wof-md-serve)",
+		FlagSet:     mdserve.DefaultFlagSet,
+		Run:         mdserve.RunWithFlagSet,
+	},
+	"sitemap": &Command{
+		Description: "Produce a sitemap and robots.txt file (wof-md2sitemap)",
+		FlagSet:     md2sitemap.DefaultFlagSet,
+		Run:         md2sitemap.RunWithFlagSet,
+	},
+}
+
+// RunCommand runs name once for each of its sections in cfg (see config.Runs)
+// with args, the command line arguments following the command's name.
+
+func RunCommand(ctx context.Context, cfg *config.Config, name string, args []string) error {
+
+	cmd, ok := commands[name]
+
+	if !ok {
+		return fmt.Errorf("Unknown command '%s'", name)
+	}
+
+	for _, section := range cfg.Runs(name) {
+
+		fs := cmd.FlagSet()
+		fs.Init(fmt.Sprintf("wof-md %s", name), flag.ExitOnError)
+
+		fs.Parse(args)
+
+		err := cfg.Apply(fs, section)
+
+		if err != nil {
+			return err
+		}
+
+		err = cmd.Run(ctx, fs)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func usage() {
+
+	fmt.Fprintf(os.Stderr, "Usage:\n\t%s [options] command [command options] [path(N) path(N)]\n\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "Commands:\n")
+
+	names := make([]string, 0)
+
+	for name, _ := range commands {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "\t%-8s %s\n", name, commands[name].Description)
+	}
+
+	fmt.Fprintf(os.Stderr, "\nOptions:\n")
+	flag.PrintDefaults()
+}
+
+func main() {
+
+	var config_path = flag.String("config", "", fmt.Sprintf("The path to a site configuration file (JSON) whose options are used for any flags not set on the command line. If empty %s is used if it exists", default_config))
+
+	flag.Usage = usage
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		usage()
+		os.Exit(1)
+	}
+
+	path := *config_path
+
+	if path == "" {
+
+		_, err := os.Stat(default_config)
+
+		if err == nil {
+			path = default_config
+		}
+	}
+
+	var cfg *config.Config
+
+	if path != "" {
+
+		c, err := config.ReadConfig(path)
+
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		flagsets := make(map[string]*flag.FlagSet)
+
+		for name, cmd := range commands {
+			flagsets[name] = cmd.FlagSet()
+		}
+
+		err = c.Check(flagsets)
+
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		cfg = c
+	}
+
+	ctx := context.Background()
+
+	name := flag.Arg(0)
+	args := flag.Args()[1:]
+
+	err := RunCommand(ctx, cfg, name, args)
+
+	if err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,18 @@
+package main
+
+import (
+	"context"
+	"log"
+
+	"github.com/whosonfirst/go-whosonfirst-markdown/app/md2sitemap"
+)
+
+func main() {
+
+	ctx := context.Background()
+	err := md2sitemap.Run(ctx)
+
+	if err != nil {
+		log.Fatal(err)
+	}
+}
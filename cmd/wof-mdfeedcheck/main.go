@@ -0,0 +1,74 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/whosonfirst/go-whosonfirst-markdown/render"
+)
+
+func main() {
+
+	var quiet = flag.Bool("quiet", false, "Only report feeds with problems")
+
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Validate one or more RSS 2.0, Atom 1.0 or JSON Feed documents. Use \"-\" to read from STDIN.\n")
+		fmt.Fprintf(os.Stderr, "Usage:\n\t%s [options] feed(N) feed(N)\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+
+	flag.Parse()
+
+	invalid := 0
+
+	for _, path := range flag.Args() {
+
+		var fh io.ReadCloser
+
+		if path == "-" {
+			fh = os.Stdin
+		} else {
+
+			f, err := os.Open(path)
+
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			fh = f
+		}
+
+		v, err := render.ValidateFeed(fh)
+		fh.Close()
+
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		for _, w := range v.Warnings {
+			fmt.Printf("%s: warning: %s\n", path, w)
+		}
+
+		if v.Valid() {
+
+			if !*quiet {
+				fmt.Printf("%s: valid %s feed\n", path, v.Format)
+			}
+
+			continue
+		}
+
+		invalid += 1
+
+		for _, p := range v.Problems {
+			fmt.Printf("%s: %s\n", path, p)
+		}
+	}
+
+	if invalid > 0 {
+		os.Exit(1)
+	}
+}
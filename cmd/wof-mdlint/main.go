@@ -0,0 +1,18 @@
+package main
+
+import (
+	"context"
+	"log"
+
+	"github.com/whosonfirst/go-whosonfirst-markdown/app/mdlint"
+)
+
+func main() {
+
+	ctx := context.Background()
+	err := mdlint.Run(ctx)
+
+	if err != nil {
+		log.Fatal(err)
+	}
+}
@@ -1,45 +1,18 @@
 package main
 
 import (
-	"flag"
-	"fmt"
+	"context"
 	"log"
 
-	"github.com/whosonfirst/go-whosonfirst-markdown/parser"	
+	"github.com/whosonfirst/go-whosonfirst-markdown/app/mdparse"
 )
 
 func main() {
 
-	var frontmatter = flag.Bool("frontmatter", false, "Dump (Jekyll) frontmatter")
-	var body = flag.Bool("body", false, "Dump (Markdown) body")
-	var all = flag.Bool("all", false, "Dump both frontmatter and body")
-
-	flag.Parse()
-
-	if *all {
-		*frontmatter = true
-		*body = true
-	}
-
-	opts := parser.DefaultParseOptions()
-	opts.FrontMatter = *frontmatter
-	opts.Body = *body
-
-	for _, path := range flag.Args() {
-
-		fm, b, err := parser.ParseFile(path, opts)
-
-		if err != nil {
-			log.Fatal(err)
-		}
-
-		if *frontmatter {
-			fmt.Println(fm.String())
-		}
-
-		if *body {
-			fmt.Println(b.String())
-		}
+	ctx := context.Background()
+	err := mdparse.Run(ctx)
 
+	if err != nil {
+		log.Fatal(err)
 	}
 }
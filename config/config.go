@@ -0,0 +1,328 @@
+// Package config reads site configuration files for the wof-md command. A
+// configuration file is a JSON object whose keys are the names of command line
+// flags, for example:
+//
+//	{
+//		"content": [ "content" ],
+//		"templates": [ "templates" ],
+//		"header": "header",
+//		"footer": "footer",
+//		"writer": [ "fs=www" ],
+//		"html": { "mode": "directory" },
+//		"index": [ { "mode": "tags" }, { "mode": "date" } ],
+//		"feed": { "site-url": "https://example.com" }
+//	}
+//
+// Top-level options apply to every command that has a flag with the same name.
+// Objects are sections for a single command and override top-level options; a
+// list of objects runs the command once for each of them. "content" is the list
+// of directories to use when none are given on the command line. Flags given on
+// the command line always override the configuration file.
+//
+// Configuration files are JSON only, on purpose. Front matter is read by the
+// package's own parser, which only understands flat "key: value" lines, so
+// reading YAML configuration files would mean adding a YAML dependency for the
+// sake of the nested sections above.
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// the key for the directories to use when none are given on the command line
+
+const content_key = "content"
+
+// Options are flag names and their values: a string, a number, a boolean or a
+// list of those for flags that can be set more than once.
+
+type Options map[string]interface{}
+
+type Config struct {
+	Path     string
+	Options  Options
+	Sections map[string][]Options
+}
+
+// ReadConfig reads the configuration file at path.
+
+func ReadConfig(path string) (*Config, error) {
+
+	abs_path, err := filepath.Abs(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	switch strings.ToLower(filepath.Ext(abs_path)) {
+	case ".yaml", ".yml":
+		return nil, fmt.Errorf("Failed to read %s, configuration files must be JSON", abs_path)
+	}
+
+	body, err := os.ReadFile(abs_path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var raw map[string]json.RawMessage
+
+	err = json.Unmarshal(body, &raw)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse %s, %v", abs_path, err)
+	}
+
+	c := Config{
+		Path:     abs_path,
+		Options:  make(Options),
+		Sections: make(map[string][]Options),
+	}
+
+	for k, v := range raw {
+
+		trimmed := bytes.TrimSpace(v)
+
+		if len(trimmed) > 0 && trimmed[0] == '{' {
+
+			var opts Options
+
+			err := decode(trimmed, &opts)
+
+			if err != nil {
+				return nil, fmt.Errorf("Invalid section '%s' in %s, %v", k, abs_path, err)
+			}
+
+			c.Sections[k] = []Options{opts}
+			continue
+		}
+
+		var runs []Options
+
+		if decode(trimmed, &runs) == nil && len(runs) > 0 {
+			c.Sections[k] = runs
+			continue
+		}
+
+		var value interface{}
+
+		err := decode(trimmed, &value)
+
+		if err != nil {
+			return nil, fmt.Errorf("Invalid option '%s' in %s, %v", k, abs_path, err)
+		}
+
+		c.Options[k] = value
+	}
+
+	return &c, nil
+}
+
+// Runs returns the options for each time command should be run: the command's
+// section (or sections) or a single empty set of options if it doesn't have one.
+// It is safe to call Runs on a nil *Config.
+
+func (c *Config) Runs(command string) []Options {
+
+	if c == nil || len(c.Sections[command]) == 0 {
+		return []Options{Options{}}
+	}
+
+	return c.Sections[command]
+}
+
+// Apply sets the flags in fs (which must already have been parsed) that weren't
+// set on the command line using the options in section and then the top-level
+// options. If no arguments were given on the command line the "content" option is
+// used instead. Options in section must be flags defined by fs but top-level
+// options that fs doesn't define are ignored. It is safe to call Apply on a nil
+// *Config.
+
+func (c *Config) Apply(fs *flag.FlagSet, section Options) error {
+
+	if c == nil {
+		return nil
+	}
+
+	set := make(map[string]bool)
+
+	fs.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+
+	for i, opts := range []Options{section, c.Options} {
+
+		for _, k := range opts.keys() {
+
+			if k == content_key {
+				continue
+			}
+
+			if fs.Lookup(k) == nil {
+
+				if i == 0 {
+					return fmt.Errorf("Invalid option '%s' in %s, %s has no -%s flag", k, c.Path, fs.Name(), k)
+				}
+
+				continue
+			}
+
+			if set[k] {
+				continue
+			}
+
+			values, err := stringValues(opts[k])
+
+			if err != nil {
+				return fmt.Errorf("Invalid option '%s' in %s, %v", k, c.Path, err)
+			}
+
+			for _, v := range values {
+
+				err := fs.Set(k, v)
+
+				if err != nil {
+					return fmt.Errorf("Invalid option '%s' in %s, %v", k, c.Path, err)
+				}
+			}
+
+			set[k] = true
+		}
+	}
+
+	if fs.NArg() > 0 {
+		return nil
+	}
+
+	for _, opts := range []Options{section, c.Options} {
+
+		v, ok := opts[content_key]
+
+		if !ok {
+			continue
+		}
+
+		paths, err := stringValues(v)
+
+		if err != nil {
+			return fmt.Errorf("Invalid option '%s' in %s, %v", content_key, c.Path, err)
+		}
+
+		// "--" so that paths are never mistaken for flags
+
+		return fs.Parse(append([]string{"--"}, paths...))
+	}
+
+	return nil
+}
+
+// Check returns an error if the configuration has a section for a command that
+// isn't in commands or a top-level option that none of the commands has a flag
+// for, which is almost certainly a typo.
+
+func (c *Config) Check(commands map[string]*flag.FlagSet) error {
+
+	for _, name := range sortedKeys(c.Sections) {
+
+		if commands[name] == nil {
+			return fmt.Errorf("Invalid section '%s' in %s, there is no %s command", name, c.Path, name)
+		}
+	}
+
+	for _, k := range c.Options.keys() {
+
+		if k == content_key {
+			continue
+		}
+
+		known := false
+
+		for _, fs := range commands {
+
+			if fs.Lookup(k) != nil {
+				known = true
+				break
+			}
+		}
+
+		if !known {
+			return fmt.Errorf("Invalid option '%s' in %s, no command has a -%s flag", k, c.Path, k)
+		}
+	}
+
+	return nil
+}
+
+func (opts Options) keys() []string {
+
+	keys := make([]string, 0)
+
+	for k, _ := range opts {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+	return keys
+}
+
+func sortedKeys(m map[string][]Options) []string {
+
+	keys := make([]string, 0)
+
+	for k, _ := range m {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+	return keys
+}
+
+// decode unmarshals body keeping numbers as json.Number so that, for example,
+// "per-page": 10 isn't turned into "1e+01".
+
+func decode(body []byte, v interface{}) error {
+
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+
+	return dec.Decode(v)
+}
+
+// stringValues returns v, which may be a list, as the strings to pass to
+// flag.Value.Set.
+
+func stringValues(v interface{}) ([]string, error) {
+
+	switch t := v.(type) {
+	case []interface{}:
+
+		values := make([]string, 0)
+
+		for _, i := range t {
+
+			switch i.(type) {
+			case []interface{}, map[string]interface{}:
+				return nil, errors.New("Lists can only contain strings, numbers and booleans")
+			}
+
+			values = append(values, fmt.Sprintf("%v", i))
+		}
+
+		return values, nil
+
+	case map[string]interface{}:
+		return nil, errors.New("Expected a string, number, boolean or list")
+	case nil:
+		return nil, errors.New("Expected a string, number, boolean or list, not null")
+	default:
+		return []string{fmt.Sprintf("%v", t)}, nil
+	}
+}
@@ -0,0 +1,99 @@
+package flags
+
+import (
+	"flag"
+	"strings"
+)
+
+// PassFlag records the values given for one of the flags of another tool (for
+// example wof-md2idx) so that they can be passed on to it. Values are checked
+// using the other tool's flag so that mistakes are reported before anything is
+// built.
+
+type PassFlag struct {
+	values []string
+	check  flag.Value
+}
+
+func NewPassFlag(check flag.Value) *PassFlag {
+
+	fl := PassFlag{
+		values: make([]string, 0),
+		check:  check,
+	}
+
+	return &fl
+}
+
+func (fl *PassFlag) String() string {
+
+	if fl == nil {
+		return ""
+	}
+
+	return strings.Join(fl.values, ",")
+}
+
+func (fl *PassFlag) Set(value string) error {
+
+	err := fl.check.Set(value)
+
+	if err != nil {
+		return err
+	}
+
+	fl.values = append(fl.values, value)
+	return nil
+}
+
+func (fl *PassFlag) IsBoolFlag() bool {
+
+	b, ok := fl.check.(interface{ IsBoolFlag() bool })
+	return ok && b.IsBoolFlag()
+}
+
+// Value returns the last value given for the flag or def if there isn't one.
+
+func (fl *PassFlag) Value(def string) string {
+
+	if len(fl.values) == 0 {
+		return def
+	}
+
+	return fl.values[len(fl.values)-1]
+}
+
+// Values returns every value given for the flag, in order.
+
+func (fl *PassFlag) Values() []string {
+	return fl.values
+}
+
+// AddPassFlags defines a PassFlag in fs for each flag in tool_fs for which pass
+// returns true, unless fs already defines a flag with the same name, and adds it
+// to passed.
+
+func AddPassFlags(fs *flag.FlagSet, tool_fs *flag.FlagSet, pass func(string) bool, passed map[string]*PassFlag) {
+
+	tool_fs.VisitAll(func(f *flag.Flag) {
+
+		if !pass(f.Name) || fs.Lookup(f.Name) != nil {
+			return
+		}
+
+		fl := NewPassFlag(f.Value)
+
+		passed[f.Name] = fl
+		fs.Var(fl, f.Name, f.Usage)
+
+		// flag.PrintDefaults only leaves out the zero values of the flag
+		// types it knows about
+
+		switch f.DefValue {
+		case "false", "0", "[]", "":
+			// pass
+		default:
+			fs.Lookup(f.Name).DefValue = f.DefValue
+		}
+	})
+}
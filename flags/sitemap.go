@@ -0,0 +1,70 @@
+package flags
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/whosonfirst/go-whosonfirst-markdown/render"
+)
+
+type SitemapPriorityFlags []*render.SitemapPriority
+
+func (fl *SitemapPriorityFlags) String() string {
+
+	rules := make([]string, len(*fl))
+
+	for idx, p := range *fl {
+		rules[idx] = fmt.Sprintf("%s=%0.1f", p.Pattern.String(), p.Priority)
+	}
+
+	return strings.Join(rules, " ")
+}
+
+// Set parses a REGEXP=PRIORITY rule, for example "^/blog/tags/=0.3"
+
+func (fl *SitemapPriorityFlags) Set(value string) error {
+
+	idx := strings.LastIndex(value, "=")
+
+	if idx == -1 {
+		return errors.New("Invalid priority rule, expected REGEXP=PRIORITY")
+	}
+
+	re, err := regexp.Compile(value[0:idx])
+
+	if err != nil {
+		return err
+	}
+
+	priority, err := strconv.ParseFloat(value[idx+1:], 64)
+
+	if err != nil {
+		return err
+	}
+
+	if priority < 0.0 || priority > 1.0 {
+		return errors.New("Invalid priority, must be between 0.0 and 1.0")
+	}
+
+	p := render.SitemapPriority{
+		Pattern:  re,
+		Priority: priority,
+	}
+
+	*fl = append(*fl, &p)
+	return nil
+}
+
+type MultiStringFlags []string
+
+func (fl *MultiStringFlags) String() string {
+	return strings.Join(*fl, " ")
+}
+
+func (fl *MultiStringFlags) Set(value string) error {
+	*fl = append(*fl, value)
+	return nil
+}
@@ -10,7 +10,8 @@ import (
 	text_template "text/template"
 
 	"github.com/whosonfirst/go-whosonfirst-crawl"
-	"github.com/whosonfirst/go-whosonfirst-markdown/uri"	
+	"github.com/whosonfirst/go-whosonfirst-markdown/render"
+	"github.com/whosonfirst/go-whosonfirst-markdown/uri"
 )
 
 type HTMLTemplateFlags []string
@@ -116,6 +117,7 @@ func (t *FeedTemplateFlags) Parse() (*text_template.Template, error) {
 		"plus1": func(x int) int {
 			return x + 1
 		},
+		"georss_point": render.GeoRSSPoint,
 	}
 
 	return text_template.New("debug").Funcs(fns).ParseFiles(*t...)
@@ -1,12 +1,13 @@
 package flags
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	_ "log"
 	"strings"
 
-	"github.com/whosonfirst/go-whosonfirst-markdown/writer"	
+	"github.com/whosonfirst/go-whosonfirst-markdown/writer"
 )
 
 type WriterFlags []writer.Writer
@@ -52,3 +53,21 @@ func (fl *WriterFlags) Set(value string) error {
 func (fl *WriterFlags) ToWriter() (writer.Writer, error) {
 	return writer.NewMultiWriter(*fl...)
 }
+
+// ToWriterWithContext is like ToWriter except that if there are no flags the writer
+// stored in ctx under "writer", if there is one, is returned instead. This lets a
+// program that runs other tools (wof-md build, for example) decide where they write.
+
+func (fl *WriterFlags) ToWriterWithContext(ctx context.Context) (writer.Writer, error) {
+
+	if len(*fl) == 0 {
+
+		wr, ok := ctx.Value("writer").(writer.Writer)
+
+		if ok {
+			return wr, nil
+		}
+	}
+
+	return fl.ToWriter()
+}
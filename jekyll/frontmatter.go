@@ -16,14 +16,26 @@ func init() {
 layout: {{ .Layout }}
 permalink: {{ .Permalink }}
 published: {{ .Published }}
-title: {{ .Title }}
+{{ if .Order }}order: {{ .Order }}
+{{ end }}title: {{ .Title }}
 date: {{ if .Date }}{{ .Date }}{{ end }}
-category: {{ .Category}}
+{{ if .LastModified }}last_modified: {{ .LastModified }}
+{{ end }}category: {{ .Category}}
 excerpt: {{ .Excerpt }}
 authors: {{ .Authors }}
 image: {{ .Image }}
 tags: {{ .Tags }}
----`
+{{ if .Series }}series: {{ .Series }}
+{{ if .SeriesPart }}series_part: {{ .SeriesPart }}
+{{ end }}{{ end }}{{ if .Enclosure }}enclosure: {{ .Enclosure.URL }}
+enclosure_type: {{ .Enclosure.Type }}
+enclosure_length: {{ .Enclosure.Length }}
+duration: {{ .Enclosure.Duration }}
+{{ end }}places: {{ .Places }}
+{{ if .Coordinates }}latitude: {{ .Coordinates.Latitude }}
+longitude: {{ .Coordinates.Longitude }}
+{{ end }}{{ range $k, $v := .Custom }}{{ $k }}: {{ $v }}
+{{ end }}---`
 
 	t, err := template.New("frontmatter").Parse(tm)
 
@@ -39,15 +51,41 @@ type FrontMatter struct {
 	Layout    string
 	Permalink string
 	Published bool
+	Order     int
 	// out-of-the-box
-	Category string
-	Date     *time.Time
+	Category     string
+	Date         *time.Time
+	LastModified *time.Time
 	// custom
-	Title   string
-	Excerpt string
-	Image   string
-	Authors []string
-	Tags    []string
+	Title     string
+	Excerpt   string
+	Image     string
+	Authors   []string
+	Tags      []string
+	Enclosure *Enclosure
+	// multi-part posts
+	Series     string
+	SeriesPart int
+	// whosonfirst
+	Places      []int64
+	Coordinates *Coordinates
+	// anything else
+	Custom map[string][]string
+}
+
+// Enclosure is an audio or video file (for example a talk recording) attached to
+// a post. Length is in bytes and Duration is "HH:MM:SS", "MM:SS" or seconds.
+
+type Enclosure struct {
+	URL      string
+	Length   int64
+	Type     string
+	Duration string
+}
+
+type Coordinates struct {
+	Latitude  float64
+	Longitude float64
 }
 
 func (fm *FrontMatter) String() string {
@@ -68,17 +106,69 @@ func (fm *FrontMatter) String() string {
 func EmptyFrontMatter() *FrontMatter {
 
 	fm := FrontMatter{
-		Title:     "",
-		Excerpt:   "",
-		Image:     "",
-		Layout:    "",
-		Category:  "",
-		Published: false,
-		Authors:   make([]string, 0),
-		Tags:      make([]string, 0),
-		Date:      nil,
-		Permalink: "",
+		Title:       "",
+		Excerpt:     "",
+		Image:       "",
+		Layout:      "",
+		Category:    "",
+		Published:   true,
+		Authors:     make([]string, 0),
+		Tags:        make([]string, 0),
+		Places:      make([]int64, 0),
+		Coordinates: nil,
+		Date:        nil,
+		Permalink:   "",
+		Custom:      make(map[string][]string),
 	}
 
 	return &fm
 }
+
+// Values returns the value(s) for a front matter key, including custom keys,
+// as a list of strings. It returns an empty list if the key is not set.
+
+func (fm *FrontMatter) Values(key string) []string {
+
+	switch key {
+	case "authors":
+		return fm.Authors
+	case "tags":
+		return fm.Tags
+	case "category", "layout", "permalink", "title", "excerpt", "image", "series":
+
+		var v string
+
+		switch key {
+		case "category":
+			v = fm.Category
+		case "layout":
+			v = fm.Layout
+		case "permalink":
+			v = fm.Permalink
+		case "title":
+			v = fm.Title
+		case "excerpt":
+			v = fm.Excerpt
+		case "series":
+			v = fm.Series
+		default:
+			v = fm.Image
+		}
+
+		if v == "" {
+			return []string{}
+		}
+
+		return []string{v}
+
+	default:
+
+		v, ok := fm.Custom[key]
+
+		if !ok {
+			return []string{}
+		}
+
+		return v
+	}
+}
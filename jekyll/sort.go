@@ -0,0 +1,90 @@
+package jekyll
+
+import (
+	"sort"
+)
+
+// ComparePosts defines the order in which posts are listed everywhere (indexes,
+// feeds, etc.). It returns a negative number if a should be listed before b, a
+// positive number if b should be listed before a and 0 if they are equivalent.
+//
+// Posts are ordered by:
+//
+// 1. Their full date (timestamp), most recent first. Posts without a date are listed last.
+// 2. Their "order" (or "weight") front matter, lowest first. Posts without an order have a weight of 0.
+// 3. Their permalink, alphabetically.
+
+func ComparePosts(a *FrontMatter, b *FrontMatter) int {
+
+	switch {
+	case a.Date == nil && b.Date != nil:
+		return 1
+	case a.Date != nil && b.Date == nil:
+		return -1
+	case a.Date != nil && b.Date != nil:
+
+		if a.Date.After(*b.Date) {
+			return -1
+		}
+
+		if a.Date.Before(*b.Date) {
+			return 1
+		}
+	}
+
+	if a.Order != b.Order {
+
+		if a.Order < b.Order {
+			return -1
+		}
+
+		return 1
+	}
+
+	switch {
+	case a.Permalink < b.Permalink:
+		return -1
+	case a.Permalink > b.Permalink:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// CompareSeriesParts defines the order of the posts in a series: by their "series_part"
+// front matter, lowest first, with posts without a part listed last and then by date,
+// oldest first.
+
+func CompareSeriesParts(a *FrontMatter, b *FrontMatter) int {
+
+	switch {
+	case a.SeriesPart > 0 && b.SeriesPart <= 0:
+		return -1
+	case a.SeriesPart <= 0 && b.SeriesPart > 0:
+		return 1
+	case a.SeriesPart != b.SeriesPart:
+		return a.SeriesPart - b.SeriesPart
+	}
+
+	// ComparePosts is most recent first
+
+	return ComparePosts(b, a)
+}
+
+// SortSeries sorts the posts in a series in place according to CompareSeriesParts.
+
+func SortSeries(posts []*FrontMatter) {
+
+	sort.SliceStable(posts, func(i, j int) bool {
+		return CompareSeriesParts(posts[i], posts[j]) < 0
+	})
+}
+
+// SortPosts sorts posts in place according to ComparePosts.
+
+func SortPosts(posts []*FrontMatter) {
+
+	sort.SliceStable(posts, func(i, j int) bool {
+		return ComparePosts(posts[i], posts[j]) < 0
+	})
+}
@@ -0,0 +1,153 @@
+package jekyll
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func testPost(permalink string, date string, order int) *FrontMatter {
+
+	fm := EmptyFrontMatter()
+	fm.Permalink = permalink
+	fm.Order = order
+
+	if date != "" {
+
+		t, err := time.Parse(time.RFC3339, date)
+
+		if err != nil {
+			panic(err)
+		}
+
+		fm.Date = &t
+	}
+
+	return fm
+}
+
+func TestComparePosts(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		a        *FrontMatter
+		b        *FrontMatter
+		expected int
+	}{
+		{
+			name:     "more recent first",
+			a:        testPost("/a/", "2018-02-01T00:00:00Z", 0),
+			b:        testPost("/b/", "2018-01-01T00:00:00Z", 0),
+			expected: -1,
+		},
+		{
+			name:     "older last",
+			a:        testPost("/a/", "2018-01-01T00:00:00Z", 0),
+			b:        testPost("/b/", "2018-02-01T00:00:00Z", 0),
+			expected: 1,
+		},
+		{
+			name:     "time of day",
+			a:        testPost("/a/", "2018-01-01T09:00:00Z", 0),
+			b:        testPost("/b/", "2018-01-01T17:00:00Z", 0),
+			expected: 1,
+		},
+		{
+			name:     "same instant in different zones",
+			a:        testPost("/a/", "2018-01-01T12:00:00+02:00", 0),
+			b:        testPost("/b/", "2018-01-01T10:00:00Z", 0),
+			expected: -1,
+		},
+		{
+			name:     "no date last",
+			a:        testPost("/a/", "", 0),
+			b:        testPost("/b/", "2018-01-01T00:00:00Z", 0),
+			expected: 1,
+		},
+		{
+			name:     "date before no date",
+			a:        testPost("/a/", "2018-01-01T00:00:00Z", 0),
+			b:        testPost("/b/", "", 0),
+			expected: -1,
+		},
+		{
+			name:     "lower order first",
+			a:        testPost("/b/", "2018-01-01T00:00:00Z", 1),
+			b:        testPost("/a/", "2018-01-01T00:00:00Z", 2),
+			expected: -1,
+		},
+		{
+			name:     "negative order before no order",
+			a:        testPost("/b/", "2018-01-01T00:00:00Z", 0),
+			b:        testPost("/a/", "2018-01-01T00:00:00Z", -1),
+			expected: 1,
+		},
+		{
+			name:     "order without dates",
+			a:        testPost("/b/", "", 1),
+			b:        testPost("/a/", "", 2),
+			expected: -1,
+		},
+		{
+			name:     "date before order",
+			a:        testPost("/a/", "2018-01-01T00:00:00Z", 5),
+			b:        testPost("/b/", "2017-01-01T00:00:00Z", 1),
+			expected: -1,
+		},
+		{
+			name:     "permalink",
+			a:        testPost("/a/", "2018-01-01T00:00:00Z", 0),
+			b:        testPost("/b/", "2018-01-01T00:00:00Z", 0),
+			expected: -1,
+		},
+		{
+			name:     "equivalent",
+			a:        testPost("/a/", "2018-01-01T00:00:00Z", 0),
+			b:        testPost("/a/", "2018-01-01T00:00:00Z", 0),
+			expected: 0,
+		},
+	}
+
+	for _, tt := range tests {
+
+		t.Run(tt.name, func(t *testing.T) {
+
+			v := ComparePosts(tt.a, tt.b)
+
+			if v != tt.expected {
+				t.Errorf("Expected %d, got %d", tt.expected, v)
+			}
+
+			v = ComparePosts(tt.b, tt.a)
+
+			if v != -tt.expected {
+				t.Errorf("Expected %d with the posts swapped, got %d", -tt.expected, v)
+			}
+		})
+	}
+}
+
+func TestSortPosts(t *testing.T) {
+
+	posts := []*FrontMatter{
+		testPost("/undated/", "", 0),
+		testPost("/old/", "2017-01-01T00:00:00Z", 0),
+		testPost("/second/", "2018-01-01T00:00:00Z", 2),
+		testPost("/first/", "2018-01-01T00:00:00Z", 1),
+		testPost("/new/", "2019-01-01T00:00:00Z", 0),
+	}
+
+	SortPosts(posts)
+
+	permalinks := make([]string, 0)
+
+	for _, fm := range posts {
+		permalinks = append(permalinks, fm.Permalink)
+	}
+
+	expected := []string{"/new/", "/first/", "/second/", "/old/", "/undated/"}
+
+	if !reflect.DeepEqual(permalinks, expected) {
+		t.Errorf("Expected %v, got %v", expected, permalinks)
+	}
+}
@@ -0,0 +1,296 @@
+// Package lint checks a directory of posts for problems that the other tools
+// either fail on, one post at a time, or silently work around: posts that can't
+// be parsed, posts without a title, posts that would be written to the same
+// page, authors without profiles, tags (and so on) missing from a taxonomy and
+// names that collide once they are turned into paths.
+package lint
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/whosonfirst/go-whosonfirst-crawl"
+	"github.com/whosonfirst/go-whosonfirst-markdown"
+	"github.com/whosonfirst/go-whosonfirst-markdown/authors"
+	"github.com/whosonfirst/go-whosonfirst-markdown/render"
+	"github.com/whosonfirst/go-whosonfirst-markdown/site"
+	"github.com/whosonfirst/go-whosonfirst-markdown/taxonomy"
+)
+
+type LintOptions struct {
+	// the name of the Markdown files to check, for example index.md
+	Input string
+	// the name of the HTML files they are rendered to, used to find posts that
+	// would be written to the same page
+	Output string
+	// if not nil every author should have a profile
+	Authors *authors.Profiles
+	// if not nil every author, category and tag should be a term (or an alias)
+	// in the corresponding taxonomy, if there is one
+	Taxonomies *taxonomy.Taxonomies
+}
+
+func DefaultLintOptions() *LintOptions {
+
+	opts := LintOptions{
+		Input:  "index.md",
+		Output: "index.html",
+	}
+
+	return &opts
+}
+
+// Problem is a single problem with a post. Path is the post's Markdown file, or
+// the directory being checked for problems that involve more than one post.
+
+type Problem struct {
+	Path    string
+	Message string
+}
+
+func (p *Problem) String() string {
+	return fmt.Sprintf("%s: %s", p.Path, p.Message)
+}
+
+// LintDirectory checks every file named opts.Input in root, including drafts and
+// posts dated in the future, and returns the problems sorted by path. An error is
+// only returned if root can not be crawled.
+
+func LintDirectory(ctx context.Context, root string, opts *LintOptions) ([]*Problem, error) {
+
+	abs_root, err := filepath.Abs(root)
+
+	if err != nil {
+		return nil, err
+	}
+
+	mu := new(sync.Mutex)
+
+	docs := make([]*markdown.Document, 0)
+	problems := make([]*Problem, 0)
+
+	site_opts := site.DefaultSiteOptions()
+	site_opts.Input = opts.Input
+
+	cb := func(path string, info os.FileInfo) error {
+
+		select {
+		case <-ctx.Done():
+			return nil
+		default:
+			// pass
+		}
+
+		if info.IsDir() || filepath.Base(path) != opts.Input {
+			return nil
+		}
+
+		doc, err := site.ParseDocument(path, site_opts)
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		if err != nil {
+			problems = append(problems, &Problem{Path: path, Message: fmt.Sprintf("Failed to parse, %v", err)})
+			return nil
+		}
+
+		docs = append(docs, doc)
+		return nil
+	}
+
+	c := crawl.NewCrawler(abs_root)
+	err = c.CrawlWithContext(ctx, cb)
+
+	if err != nil {
+		return nil, err
+	}
+
+	markdown.SortDocuments(docs)
+
+	for _, doc := range docs {
+		problems = append(problems, lintDocument(doc, opts)...)
+	}
+
+	problems = append(problems, lintPages(abs_root, docs, opts)...)
+	problems = append(problems, lintSlugs(abs_root, docs, opts)...)
+	problems = append(problems, lintSeries(docs)...)
+
+	sort.SliceStable(problems, func(i, j int) bool {
+		return problems[i].Path < problems[j].Path
+	})
+
+	return problems, nil
+}
+
+func lintDocument(doc *markdown.Document, opts *LintOptions) []*Problem {
+
+	problems := make([]*Problem, 0)
+
+	add := func(msg string, args ...interface{}) {
+		problems = append(problems, &Problem{Path: doc.Path, Message: fmt.Sprintf(msg, args...)})
+	}
+
+	fm := doc.FrontMatter
+
+	if fm.Title == "" {
+		add("Missing title")
+	}
+
+	if opts.Authors != nil {
+
+		for _, a := range fm.Authors {
+
+			if opts.Authors.Profile(a) == nil {
+				add("No profile for author '%s'", a)
+			}
+		}
+	}
+
+	terms := map[string][]string{
+		"authors": fm.Authors,
+		"tags":    fm.Tags,
+	}
+
+	if fm.Category != "" {
+		terms["category"] = []string{fm.Category}
+	}
+
+	for _, name := range []string{"authors", "category", "tags"} {
+
+		t := opts.Taxonomies.Taxonomy(name)
+
+		if t == nil {
+			continue
+		}
+
+		for _, raw := range terms[name] {
+
+			if t.Term(raw) == nil {
+				add("'%s' is not defined in the %s taxonomy", raw, name)
+			}
+		}
+	}
+
+	if fm.SeriesPart != 0 && fm.Series == "" {
+		add("Series part %d but no series", fm.SeriesPart)
+	}
+
+	return problems
+}
+
+// lintPages finds posts that would be written to the same page by wof-md2html.
+
+func lintPages(root string, docs []*markdown.Document, opts *LintOptions) []*Problem {
+
+	problems := make([]*Problem, 0)
+
+	html_opts := render.DefaultHTMLOptions()
+	html_opts.Output = opts.Output
+
+	pages := make(map[string]string)
+
+	for _, doc := range docs {
+
+		out_path := render.HTMLOutputPath(doc.FrontMatter, doc.Path, root, html_opts)
+		other, ok := pages[out_path]
+
+		if ok {
+			problems = append(problems, &Problem{Path: doc.Path, Message: fmt.Sprintf("Written to the same page (%s) as %s", out_path, other)})
+			continue
+		}
+
+		pages[out_path] = doc.Path
+	}
+
+	return problems
+}
+
+// lintSlugs finds authors, categories and tags that would be written to the same
+// index page by wof-md2idx, see taxonomy.CheckSlugs.
+
+func lintSlugs(root string, docs []*markdown.Document, opts *LintOptions) []*Problem {
+
+	problems := make([]*Problem, 0)
+
+	for _, name := range []string{"authors", "category", "tags"} {
+
+		t := opts.Taxonomies.Taxonomy(name)
+
+		seen := make(map[string]bool)
+		keys := make([]string, 0)
+
+		for _, doc := range docs {
+
+			fm := doc.FrontMatter
+			values := fm.Tags
+
+			switch name {
+			case "authors":
+				values = fm.Authors
+			case "category":
+				values = []string{fm.Category}
+			}
+
+			for _, v := range values {
+
+				k := t.Canonical(v)
+
+				if k == "" || seen[k] {
+					continue
+				}
+
+				seen[k] = true
+				keys = append(keys, k)
+			}
+		}
+
+		slug := t.Slug
+
+		if name == "category" {
+			slug = t.SlugPath
+		}
+
+		err := taxonomy.CheckSlugs(keys, slug)
+
+		if err != nil {
+			problems = append(problems, &Problem{Path: root, Message: fmt.Sprintf("Invalid %s, %v", name, err)})
+		}
+	}
+
+	return problems
+}
+
+// lintSeries finds series with more than one post for the same part.
+
+func lintSeries(docs []*markdown.Document) []*Problem {
+
+	problems := make([]*Problem, 0)
+
+	parts := make(map[string]string)
+
+	for _, doc := range docs {
+
+		fm := doc.FrontMatter
+
+		if fm.Series == "" || fm.SeriesPart == 0 {
+			continue
+		}
+
+		k := fmt.Sprintf("%s#%d", fm.Series, fm.SeriesPart)
+		other, ok := parts[k]
+
+		if ok {
+			problems = append(problems, &Problem{Path: doc.Path, Message: fmt.Sprintf("Same part (%d) of series '%s' as %s", fm.SeriesPart, fm.Series, other)})
+			continue
+		}
+
+		parts[k] = doc.Path
+	}
+
+	return problems
+}
@@ -3,13 +3,16 @@ package markdown
 import (
 	"bytes"
 	"fmt"
-	
+	"sort"
+
 	"github.com/whosonfirst/go-whosonfirst-markdown/jekyll"
 )
 
 type Document struct {
 	FrontMatter *jekyll.FrontMatter
 	Body        *Body
+	// the path to the source Markdown file, if known
+	Path string
 }
 
 func NewDocument(fm *jekyll.FrontMatter, body *Body) (*Document, error) {
@@ -29,3 +32,12 @@ type Body struct {
 func (b *Body) String() string {
 	return fmt.Sprintf("%s", b.Bytes())
 }
+
+// SortDocuments sorts docs in place according to jekyll.ComparePosts.
+
+func SortDocuments(docs []*Document) {
+
+	sort.SliceStable(docs, func(i, j int) bool {
+		return jekyll.ComparePosts(docs[i].FrontMatter, docs[j].FrontMatter) < 0
+	})
+}
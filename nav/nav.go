@@ -0,0 +1,276 @@
+// Package nav provides navigation between posts: the chronologically adjacent
+// posts, posts that share tags and posts in the same series. Everything is derived
+// from the front matter of all the posts in a site which can be gathered using
+// the site package.
+package nav
+
+import (
+	"path/filepath"
+	"sort"
+
+	"github.com/whosonfirst/go-whosonfirst-markdown"
+	"github.com/whosonfirst/go-whosonfirst-markdown/jekyll"
+)
+
+type NavigationOptions struct {
+	// the maximum number of related posts to return
+	Related int
+}
+
+func DefaultNavigationOptions() *NavigationOptions {
+
+	opts := NavigationOptions{
+		Related: 5,
+	}
+
+	return &opts
+}
+
+// Context is the navigation for a single post. Prev is the previous (older) post
+// and Next the next (newer) post. RelatedByTags are the posts that share the most
+// tags with the post, most shared tags first, and SameSeries are the other posts in
+// the same series (see jekyll.CompareSeriesParts for ordering). Series is nil if
+// the post is not part of a series.
+
+type Context struct {
+	Prev          *jekyll.FrontMatter
+	Next          *jekyll.FrontMatter
+	RelatedByTags []*jekyll.FrontMatter
+	SameSeries    []*jekyll.FrontMatter
+	Series        *Series
+}
+
+// Series describes a post's place in a series. Parts are all the posts in the
+// series, including the current post, and Index is the (0-based) position of
+// the current post in Parts. Part and Total are for "Part 2 of 5" and Previous
+// and Next are the adjacent parts, if any.
+
+type Series struct {
+	Name     string
+	Parts    []*jekyll.FrontMatter
+	Index    int
+	Part     int
+	Total    int
+	Previous *jekyll.FrontMatter
+	Next     *jekyll.FrontMatter
+}
+
+// NewSeries returns a Series for fm without any of the other parts, for when the
+// rest of the site isn't known. It returns nil if fm is not part of a series.
+
+func NewSeries(fm *jekyll.FrontMatter) *Series {
+
+	if fm.Series == "" {
+		return nil
+	}
+
+	s := Series{
+		Name:  fm.Series,
+		Parts: []*jekyll.FrontMatter{fm},
+		Index: 0,
+		Part:  fm.SeriesPart,
+		Total: 0,
+	}
+
+	return &s
+}
+
+type Navigation struct {
+	docs    []*markdown.Document
+	offsets map[string]int
+	opts    *NavigationOptions
+}
+
+// NewNavigation returns a new Navigation for docs. Documents are looked up by
+// their (absolute) Path so that must be defined.
+
+func NewNavigation(docs []*markdown.Document, opts *NavigationOptions) (*Navigation, error) {
+
+	sorted := make([]*markdown.Document, len(docs))
+	copy(sorted, docs)
+
+	markdown.SortDocuments(sorted)
+
+	offsets := make(map[string]int)
+
+	for i, d := range sorted {
+
+		abs_path, err := filepath.Abs(d.Path)
+
+		if err != nil {
+			return nil, err
+		}
+
+		offsets[abs_path] = i
+	}
+
+	n := Navigation{
+		docs:    sorted,
+		offsets: offsets,
+		opts:    opts,
+	}
+
+	return &n, nil
+}
+
+// Context returns the navigation for the document at path or nil if it is not
+// known. It is safe to call Context on a nil *Navigation.
+
+func (n *Navigation) Context(path string) *Context {
+
+	if n == nil {
+		return nil
+	}
+
+	abs_path, err := filepath.Abs(path)
+
+	if err != nil {
+		return nil
+	}
+
+	idx, ok := n.offsets[abs_path]
+
+	if !ok {
+		return nil
+	}
+
+	fm := n.docs[idx].FrontMatter
+
+	c := Context{
+		RelatedByTags: n.relatedByTags(idx),
+		SameSeries:    make([]*jekyll.FrontMatter, 0),
+		Series:        n.series(idx),
+	}
+
+	if c.Series != nil {
+
+		for _, p := range c.Series.Parts {
+
+			if p != fm {
+				c.SameSeries = append(c.SameSeries, p)
+			}
+		}
+	}
+
+	// documents are sorted most recent first with undated documents last
+	// (and undated documents don't have a previous or next document)
+
+	if fm.Date != nil {
+
+		if idx > 0 {
+			c.Next = n.docs[idx-1].FrontMatter
+		}
+
+		if idx < len(n.docs)-1 && n.docs[idx+1].FrontMatter.Date != nil {
+			c.Prev = n.docs[idx+1].FrontMatter
+		}
+	}
+
+	return &c
+}
+
+func (n *Navigation) relatedByTags(idx int) []*jekyll.FrontMatter {
+
+	fm := n.docs[idx].FrontMatter
+
+	tags := make(map[string]bool)
+
+	for _, t := range fm.Tags {
+		tags[t] = true
+	}
+
+	type candidate struct {
+		fm     *jekyll.FrontMatter
+		shared int
+	}
+
+	candidates := make([]*candidate, 0)
+
+	for i, d := range n.docs {
+
+		if i == idx {
+			continue
+		}
+
+		shared := 0
+
+		for _, t := range d.FrontMatter.Tags {
+
+			if tags[t] {
+				shared += 1
+			}
+		}
+
+		if shared > 0 {
+			candidates = append(candidates, &candidate{d.FrontMatter, shared})
+		}
+	}
+
+	// candidates are already in (reverse chronological) order so a stable
+	// sort means more recent posts win ties
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].shared > candidates[j].shared
+	})
+
+	related := make([]*jekyll.FrontMatter, 0)
+
+	for _, c := range candidates {
+
+		if n.opts.Related > 0 && len(related) >= n.opts.Related {
+			break
+		}
+
+		related = append(related, c.fm)
+	}
+
+	return related
+}
+
+func (n *Navigation) series(idx int) *Series {
+
+	fm := n.docs[idx].FrontMatter
+
+	if fm.Series == "" {
+		return nil
+	}
+
+	parts := make([]*jekyll.FrontMatter, 0)
+
+	for _, d := range n.docs {
+
+		if d.FrontMatter.Series == fm.Series {
+			parts = append(parts, d.FrontMatter)
+		}
+	}
+
+	jekyll.SortSeries(parts)
+
+	s := Series{
+		Name:  fm.Series,
+		Parts: parts,
+		Total: len(parts),
+	}
+
+	for i, p := range parts {
+
+		if p != fm {
+			continue
+		}
+
+		s.Index = i
+		s.Part = i + 1
+
+		if i > 0 {
+			s.Previous = parts[i-1]
+		}
+
+		if i < len(parts)-1 {
+			s.Next = parts[i+1]
+		}
+
+		break
+	}
+
+	return &s
+}
@@ -9,12 +9,13 @@ import (
 	"os"
 	"path/filepath"
 	"regexp"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/djherbis/times"
 	"github.com/whosonfirst/go-whosonfirst-markdown"
-	"github.com/whosonfirst/go-whosonfirst-markdown/jekyll"	
+	"github.com/whosonfirst/go-whosonfirst-markdown/jekyll"
 )
 
 type ParseOptions struct {
@@ -131,6 +132,16 @@ func Parse(md io.ReadCloser, opts *ParseOptions) (*jekyll.FrontMatter, *markdown
 	lineno := 0
 	is_jekyll := false
 
+	// latitude and longitude are separate keys so we wait until we've
+	// seen everything before assigning fm.Coordinates
+
+	var latitude *float64
+	var longitude *float64
+
+	// likewise enclosures
+
+	enclosure := new(jekyll.Enclosure)
+
 	for scanner.Scan() {
 
 		lineno += 1
@@ -170,8 +181,7 @@ func Parse(md io.ReadCloser, opts *ParseOptions) (*jekyll.FrontMatter, *markdown
 					fm.Category = string2string(value)
 				case "date":
 
-					dt := string2string(value)
-					t, err := time.Parse("2006-01-02", dt)
+					t, err := string2time(value)
 
 					if err != nil {
 						return nil, nil, err
@@ -179,16 +189,97 @@ func Parse(md io.ReadCloser, opts *ParseOptions) (*jekyll.FrontMatter, *markdown
 
 					fm.Date = &t
 
+				case "duration", "enclosure_duration":
+					enclosure.Duration = string2string(value)
+				case "enclosure", "enclosure_url", "audio", "video":
+					enclosure.URL = string2string(value)
+				case "enclosure_length":
+
+					i, err := strconv.ParseInt(string2string(value), 10, 64)
+
+					if err != nil {
+						return nil, nil, err
+					}
+
+					enclosure.Length = i
+
+				case "enclosure_type":
+					enclosure.Type = string2string(value)
 				case "excerpt":
 					fm.Excerpt = string2string(value)
+				case "geo":
+
+					lat, lon, err := string2coords(value)
+
+					if err != nil {
+						return nil, nil, err
+					}
+
+					latitude = &lat
+					longitude = &lon
+
 				case "image":
 					fm.Image = string2string(value)
+				case "latitude":
+
+					lat, err := string2float64(value)
+
+					if err != nil {
+						return nil, nil, err
+					}
+
+					latitude = &lat
+
+				case "last_modified", "last_modified_at", "modified", "updated":
+
+					t, err := string2time(value)
+
+					if err != nil {
+						return nil, nil, err
+					}
+
+					fm.LastModified = &t
+
 				case "layout":
 					fm.Layout = string2string(value)
+				case "places":
+					fm.Places = string2int64list(value)
+				case "longitude":
+
+					lon, err := string2float64(value)
+
+					if err != nil {
+						return nil, nil, err
+					}
+
+					longitude = &lon
+
+				case "order", "weight":
+
+					i, err := strconv.Atoi(string2string(value))
+
+					if err != nil {
+						return nil, nil, err
+					}
+
+					fm.Order = i
+
 				case "permalink":
 					fm.Permalink = string2string(value)
 				case "published":
 					fm.Published = string2bool(value)
+				case "series":
+					fm.Series = string2string(value)
+				case "series_part", "part":
+
+					i, err := strconv.Atoi(string2string(value))
+
+					if err != nil {
+						return nil, nil, err
+					}
+
+					fm.SeriesPart = i
+
 				case "tag":
 					fm.Tags = string2list(value)
 				case "tags":
@@ -196,8 +287,13 @@ func Parse(md io.ReadCloser, opts *ParseOptions) (*jekyll.FrontMatter, *markdown
 				case "title":
 					fm.Title = string2string(value)
 				default:
-					// log.Println("WHAT IS ", key)
-					// pass
+
+					// custom keys are stored as lists so they can be grouped
+					// on (see wof-md2idx -mode key)
+
+					if key != "" {
+						fm.Custom[key] = string2values(value)
+					}
 				}
 			}
 			continue
@@ -208,8 +304,20 @@ func Parse(md io.ReadCloser, opts *ParseOptions) (*jekyll.FrontMatter, *markdown
 		}
 	}
 
+	if latitude != nil && longitude != nil {
+
+		fm.Coordinates = &jekyll.Coordinates{
+			Latitude:  *latitude,
+			Longitude: *longitude,
+		}
+	}
+
+	if enclosure.URL != "" {
+		fm.Enclosure = enclosure
+	}
+
 	wr.Flush()
-	body := markdown.Body{&b}
+	body := markdown.Body{Buffer: &b}
 
 	return fm, &body, nil
 }
@@ -238,6 +346,115 @@ func string2list(s string) []string {
 	return l
 }
 
+// string2values parses either a list ("[a, b]") or a single value
+
+func string2values(s string) []string {
+
+	l := make([]string, 0)
+
+	if strings.HasPrefix(s, "[") {
+
+		for _, str := range string2list(s) {
+
+			str = string2string(str)
+
+			if str != "" {
+				l = append(l, str)
+			}
+		}
+
+		return l
+	}
+
+	s = string2string(s)
+
+	if s != "" {
+		l = append(l, s)
+	}
+
+	return l
+}
+
+func string2int64list(s string) []int64 {
+
+	l := make([]int64, 0)
+
+	for _, str := range string2list(s) {
+
+		str = string2string(str)
+		i, err := strconv.ParseInt(str, 10, 64)
+
+		if err != nil {
+			continue
+		}
+
+		l = append(l, i)
+	}
+
+	return l
+}
+
+// the date formats, in order, that we try to parse front matter dates with
+
+var date_formats = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05 -0700",
+	"2006-01-02 15:04:05 MST",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+func string2time(s string) (time.Time, error) {
+
+	s = string2string(s)
+
+	var t time.Time
+	var err error
+
+	for _, layout := range date_formats {
+
+		t, err = time.Parse(layout, s)
+
+		if err == nil {
+			return t, nil
+		}
+	}
+
+	return t, err
+}
+
+func string2float64(s string) (float64, error) {
+	s = string2string(s)
+	return strconv.ParseFloat(s, 64)
+}
+
+// string2coords parses "latitude, longitude" (optionally wrapped in brackets)
+
+func string2coords(s string) (float64, float64, error) {
+
+	l := string2list(string2string(s))
+
+	if len(l) != 2 {
+		return 0.0, 0.0, fmt.Errorf("Invalid coordinates '%s'", s)
+	}
+
+	lat, err := string2float64(l[0])
+
+	if err != nil {
+		return 0.0, 0.0, err
+	}
+
+	lon, err := string2float64(l[1])
+
+	if err != nil {
+		return 0.0, 0.0, err
+	}
+
+	return lat, lon, nil
+}
+
 func string2bool(s string) bool {
 
 	possible := []string{
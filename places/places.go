@@ -0,0 +1,303 @@
+package places
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+
+	"github.com/whosonfirst/go-whosonfirst-markdown/jekyll"
+)
+
+// the placetypes, in order, that we care about when rolling up posts
+// by place (country > region > locality)
+
+var Placetypes = []string{
+	"country",
+	"region",
+	"locality",
+}
+
+type Place struct {
+	Id        int64
+	Name      string
+	Placetype string
+	ParentId  int64
+	Hierarchy map[string]int64
+	Latitude  float64
+	Longitude float64
+}
+
+type LocalResolver struct {
+	root  string
+	mu    *sync.RWMutex
+	cache map[int64]*Place
+}
+
+func NewLocalResolver(root string) (*LocalResolver, error) {
+
+	abs_root, err := filepath.Abs(root)
+
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := os.Stat(abs_root)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !info.IsDir() {
+		return nil, errors.New("Places data is not a directory")
+	}
+
+	// allow people to pass either a whosonfirst-data repo or its "data"
+	// directory
+
+	data_root := filepath.Join(abs_root, "data")
+
+	info, err = os.Stat(data_root)
+
+	if err == nil && info.IsDir() {
+		abs_root = data_root
+	}
+
+	r := LocalResolver{
+		root:  abs_root,
+		mu:    new(sync.RWMutex),
+		cache: make(map[int64]*Place),
+	}
+
+	return &r, nil
+}
+
+func (r *LocalResolver) Place(id int64) (*Place, error) {
+
+	r.mu.RLock()
+	pl, ok := r.cache[id]
+	r.mu.RUnlock()
+
+	if ok {
+		return pl, nil
+	}
+
+	path, err := IdToPath(id)
+
+	if err != nil {
+		return nil, err
+	}
+
+	abs_path := filepath.Join(r.root, path)
+
+	fh, err := os.Open(abs_path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer fh.Close()
+
+	type Feature struct {
+		Properties map[string]interface{} `json:"properties"`
+	}
+
+	var f Feature
+
+	err = json.NewDecoder(fh).Decode(&f)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse %s, %v", abs_path, err)
+	}
+
+	pl = &Place{
+		Id:        id,
+		Name:      property2string(f.Properties, "wof:name"),
+		Placetype: property2string(f.Properties, "wof:placetype"),
+		ParentId:  property2int64(f.Properties, "wof:parent_id"),
+		Hierarchy: make(map[string]int64),
+		Latitude:  property2float64(f.Properties, "geom:latitude"),
+		Longitude: property2float64(f.Properties, "geom:longitude"),
+	}
+
+	if _, ok := f.Properties["lbl:latitude"]; ok {
+		pl.Latitude = property2float64(f.Properties, "lbl:latitude")
+		pl.Longitude = property2float64(f.Properties, "lbl:longitude")
+	}
+
+	// we only care about the first hierarchy, at least for now
+
+	hierarchies, ok := f.Properties["wof:hierarchy"].([]interface{})
+
+	if ok && len(hierarchies) > 0 {
+
+		h, ok := hierarchies[0].(map[string]interface{})
+
+		if ok {
+			for k, _ := range h {
+				pl.Hierarchy[k] = property2int64(h, k)
+			}
+		}
+	}
+
+	r.mu.Lock()
+	r.cache[id] = pl
+	r.mu.Unlock()
+
+	return pl, nil
+}
+
+// Ancestors returns the place itself and any of its ancestors whose placetype is
+// listed in Placetypes, ordered from the largest (country) to the smallest.
+
+func (r *LocalResolver) Ancestors(id int64) ([]*Place, error) {
+
+	pl, err := r.Place(id)
+
+	if err != nil {
+		return nil, err
+	}
+
+	ancestors := make([]*Place, 0)
+
+	for _, pt := range Placetypes {
+
+		if pt == pl.Placetype {
+			continue
+		}
+
+		k := fmt.Sprintf("%s_id", pt)
+		a_id, ok := pl.Hierarchy[k]
+
+		if !ok || a_id <= 0 || a_id == id {
+			continue
+		}
+
+		a, err := r.Place(a_id)
+
+		if err != nil {
+			return nil, err
+		}
+
+		ancestors = append(ancestors, a)
+	}
+
+	ancestors = append(ancestors, pl)
+	return ancestors, nil
+}
+
+// Coordinates returns the coordinates for a post, either those defined in its
+// front matter or failing that those of the first place it references that can
+// be resolved. It returns nil if no coordinates can be determined.
+
+func (r *LocalResolver) Coordinates(fm *jekyll.FrontMatter) *jekyll.Coordinates {
+
+	if fm.Coordinates != nil {
+		return fm.Coordinates
+	}
+
+	for _, id := range fm.Places {
+
+		pl, err := r.Place(id)
+
+		if err != nil {
+			continue
+		}
+
+		if pl.Latitude == 0.0 && pl.Longitude == 0.0 {
+			continue
+		}
+
+		coords := &jekyll.Coordinates{
+			Latitude:  pl.Latitude,
+			Longitude: pl.Longitude,
+		}
+
+		return coords
+	}
+
+	return nil
+}
+
+// IdToPath returns the relative path for a WOF ID, for example 101736545
+// becomes 101/736/545/101736545.geojson
+
+func IdToPath(id int64) (string, error) {
+
+	if id < 0 {
+		return "", errors.New("Invalid WOF ID")
+	}
+
+	str_id := strconv.FormatInt(id, 10)
+	parts := make([]string, 0)
+
+	for len(str_id) > 3 {
+		parts = append(parts, str_id[0:3])
+		str_id = str_id[3:]
+	}
+
+	if len(str_id) > 0 {
+		parts = append(parts, str_id)
+	}
+
+	fname := fmt.Sprintf("%d.geojson", id)
+	parts = append(parts, fname)
+
+	return filepath.Join(parts...), nil
+}
+
+func property2string(props map[string]interface{}, k string) string {
+
+	v, ok := props[k]
+
+	if !ok {
+		return ""
+	}
+
+	str, ok := v.(string)
+
+	if !ok {
+		return ""
+	}
+
+	return str
+}
+
+func property2int64(props map[string]interface{}, k string) int64 {
+
+	switch v := props[k].(type) {
+	case float64:
+		return int64(v)
+	case string:
+		i, err := strconv.ParseInt(v, 10, 64)
+
+		if err != nil {
+			return -1
+		}
+
+		return i
+	default:
+		return -1
+	}
+}
+
+func property2float64(props map[string]interface{}, k string) float64 {
+
+	switch v := props[k].(type) {
+	case float64:
+		return v
+	case string:
+		f, err := strconv.ParseFloat(v, 64)
+
+		if err != nil {
+			return 0.0
+		}
+
+		return f
+	default:
+		return 0.0
+	}
+}
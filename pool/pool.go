@@ -0,0 +1,200 @@
+// Package pool runs tasks (typically rendering a file) concurrently using a fixed
+// number of workers. Results, including how long each task took, are returned in
+// the order the tasks were submitted and the first failure cancels the pool's
+// context so that no new work is started.
+package pool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+type PoolOptions struct {
+	// the maximum number of tasks to run at once
+	Workers int
+	// keep running tasks after one of them fails
+	KeepGoing bool
+}
+
+func DefaultPoolOptions() *PoolOptions {
+
+	opts := PoolOptions{
+		Workers:   runtime.NumCPU(),
+		KeepGoing: false,
+	}
+
+	return &opts
+}
+
+// Result is the outcome of a single task. Seq is the (0-based) order in which the
+// task was submitted.
+
+type Result struct {
+	Seq      int
+	Name     string
+	Duration time.Duration
+	Err      error
+}
+
+// Errors are the failed tasks for a pool, in the order they were submitted.
+
+type Errors []*Result
+
+func (e Errors) Error() string {
+
+	msgs := make([]string, len(e))
+
+	for i, r := range e {
+		msgs[i] = fmt.Sprintf("%s: %v", r.Name, r.Err)
+	}
+
+	return strings.Join(msgs, "; ")
+}
+
+type Pool struct {
+	ctx     context.Context
+	cancel  context.CancelFunc
+	opts    *PoolOptions
+	slots   chan bool
+	wg      *sync.WaitGroup
+	mu      *sync.Mutex
+	seq     int
+	results []*Result
+}
+
+// NewPool returns a new Pool whose context is derived from ctx. Callers should
+// call Cancel when they are done with it.
+
+func NewPool(ctx context.Context, opts *PoolOptions) (*Pool, error) {
+
+	if opts.Workers < 1 {
+		return nil, errors.New("Pool must have at least one worker")
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+
+	p := Pool{
+		ctx:     ctx,
+		cancel:  cancel,
+		opts:    opts,
+		slots:   make(chan bool, opts.Workers),
+		wg:      new(sync.WaitGroup),
+		mu:      new(sync.Mutex),
+		results: make([]*Result, 0),
+	}
+
+	return &p, nil
+}
+
+// Context returns the pool's context, which is cancelled when a task fails
+// (unless KeepGoing is set) or Cancel is called. Tasks, and anything feeding
+// tasks to the pool such as a crawler, should derive their contexts from it.
+
+func (p *Pool) Context() context.Context {
+	return p.ctx
+}
+
+// Cancel cancels the pool's context. Like the CancelFunc returned by
+// context.WithCancel it should always be called once the pool is no longer
+// needed, even if nothing failed, to release the resources it holds.
+
+func (p *Pool) Cancel() {
+	p.cancel()
+}
+
+// Submit runs fn as soon as a worker is free, blocking until then. It returns the
+// context's error, without running fn, if the pool has been cancelled.
+
+func (p *Pool) Submit(name string, fn func() error) error {
+
+	select {
+	case <-p.ctx.Done():
+		return p.ctx.Err()
+	case p.slots <- true:
+		// pass
+	}
+
+	p.mu.Lock()
+	seq := p.seq
+	p.seq += 1
+	p.mu.Unlock()
+
+	p.wg.Add(1)
+
+	go func() {
+
+		defer func() {
+			<-p.slots
+			p.wg.Done()
+		}()
+
+		// the pool may have been cancelled while waiting for a slot
+
+		select {
+		case <-p.ctx.Done():
+			return
+		default:
+			// pass
+		}
+
+		t1 := time.Now()
+		err := fn()
+
+		r := Result{
+			Seq:      seq,
+			Name:     name,
+			Duration: time.Since(t1),
+			Err:      err,
+		}
+
+		p.mu.Lock()
+		p.results = append(p.results, &r)
+		p.mu.Unlock()
+
+		if err != nil && !p.opts.KeepGoing {
+			p.cancel()
+		}
+	}()
+
+	return nil
+}
+
+// Wait waits for all the submitted tasks to finish and returns their results in
+// the order they were submitted. Tasks that were never started because the pool
+// was cancelled are not included. If any tasks failed the error is an Errors.
+
+func (p *Pool) Wait() ([]*Result, error) {
+
+	p.wg.Wait()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	results := make([]*Result, len(p.results))
+	copy(results, p.results)
+
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Seq < results[j].Seq
+	})
+
+	failed := make(Errors, 0)
+
+	for _, r := range results {
+
+		if r.Err != nil {
+			failed = append(failed, r)
+		}
+	}
+
+	if len(failed) > 0 {
+		return results, failed
+	}
+
+	return results, nil
+}
@@ -0,0 +1,185 @@
+package publish
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/whosonfirst/go-whosonfirst-markdown/jekyll"
+)
+
+// the names of directories whose contents are always considered drafts
+
+var DraftsDirectories = []string{
+	"drafts",
+	"_drafts",
+}
+
+// Filter decides which posts are published. By default posts with "published: false"
+// front matter, posts in a drafts directory (see DraftsDirectories) and posts dated
+// after BuildTime are excluded. Drafts and Future include them anyway, for preview
+// builds.
+
+type Filter struct {
+	Drafts    bool
+	Future    bool
+	BuildTime time.Time
+}
+
+func DefaultFilter() *Filter {
+
+	f := Filter{
+		Drafts:    false,
+		Future:    false,
+		BuildTime: time.Now(),
+	}
+
+	return &f
+}
+
+// NewFilter returns a new Filter. build_time is either an RFC3339 timestamp or a
+// YYYY-MM-DD date; if it is empty the current time is used.
+
+func NewFilter(drafts bool, future bool, build_time string) (*Filter, error) {
+
+	f := DefaultFilter()
+	f.Drafts = drafts
+	f.Future = future
+
+	if build_time != "" {
+
+		t, err := ParseBuildTime(build_time)
+
+		if err != nil {
+			return nil, err
+		}
+
+		f.BuildTime = t
+	}
+
+	return f, nil
+}
+
+// NewFilterWithContext is like NewFilter except that if build_time is empty the
+// time.Time stored in ctx under "build_time", if there is one, is used instead of
+// the current time. This lets a program that runs other tools (wof-md build, for
+// example) make sure they all agree on which posts are published without changing
+// their flags.
+
+func NewFilterWithContext(ctx context.Context, drafts bool, future bool, build_time string) (*Filter, error) {
+
+	f, err := NewFilter(drafts, future, build_time)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if build_time == "" {
+
+		t, ok := ctx.Value("build_time").(time.Time)
+
+		if ok {
+			f.BuildTime = t
+		}
+	}
+
+	return f, nil
+}
+
+func ParseBuildTime(str_time string) (time.Time, error) {
+
+	for _, layout := range []string{time.RFC3339, "2006-01-02"} {
+
+		t, err := time.Parse(layout, str_time)
+
+		if err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, errors.New(fmt.Sprintf("Invalid build time '%s'. Build times should be RFC3339 timestamps or YYYY-MM-DD dates", str_time))
+}
+
+// Equal reports whether f and other include the same posts. Two nil filters are
+// equal.
+
+func (f *Filter) Equal(other *Filter) bool {
+
+	if f == nil || other == nil {
+		return f == other
+	}
+
+	return f.Drafts == other.Drafts && f.Future == other.Future && f.BuildTime.Equal(other.BuildTime)
+}
+
+// Include reports whether the post at path (with front matter fm) should be
+// rendered, indexed and syndicated. If root is not empty only the part of path
+// inside root is checked for drafts directories. It is safe to call Include on a
+// nil *Filter, in which case everything is included.
+
+func (f *Filter) Include(root string, path string, fm *jekyll.FrontMatter) bool {
+
+	if f == nil {
+		return true
+	}
+
+	if !f.Drafts && IsDraft(root, path, fm) {
+		return false
+	}
+
+	if !f.Future && f.IsFuture(fm) {
+		return false
+	}
+
+	return true
+}
+
+// IsFuture reports whether fm is dated after the filter's build time
+
+func (f *Filter) IsFuture(fm *jekyll.FrontMatter) bool {
+
+	if fm.Date == nil {
+		return false
+	}
+
+	return fm.Date.After(f.BuildTime)
+}
+
+// IsDraft reports whether a post is a draft, either because its front matter
+// says "published: false" or because it is in a drafts directory.
+
+func IsDraft(root string, path string, fm *jekyll.FrontMatter) bool {
+
+	if fm != nil && !fm.Published {
+		return true
+	}
+
+	rel_path := path
+
+	if root != "" {
+
+		abs_root, _ := filepath.Abs(root)
+		abs_path, _ := filepath.Abs(path)
+
+		rel, err := filepath.Rel(abs_root, abs_path)
+
+		if err == nil && !strings.HasPrefix(rel, "..") {
+			rel_path = rel
+		}
+	}
+
+	for _, part := range strings.Split(filepath.ToSlash(filepath.Dir(rel_path)), "/") {
+
+		for _, d := range DraftsDirectories {
+
+			if part == d {
+				return true
+			}
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,172 @@
+package render
+
+import (
+	"encoding/xml"
+	"io"
+	"time"
+
+	"github.com/whosonfirst/go-whosonfirst-markdown"
+)
+
+const AtomNamespace = "http://www.w3.org/2005/Atom"
+
+type Atom struct {
+	XMLName   xml.Name      `xml:"feed"`
+	Namespace string        `xml:"xmlns,attr"`
+	GeoRSSNS  string        `xml:"xmlns:georss,attr,omitempty"`
+	Title     string        `xml:"title"`
+	Subtitle  string        `xml:"subtitle,omitempty"`
+	Id        string        `xml:"id"`
+	Updated   string        `xml:"updated"`
+	Links     []*AtomLink   `xml:"link"`
+	Authors   []*AtomPerson `xml:"author"`
+	Generator string        `xml:"generator"`
+	Entries   []*AtomEntry  `xml:"entry"`
+}
+
+type AtomLink struct {
+	Href   string `xml:"href,attr"`
+	Rel    string `xml:"rel,attr,omitempty"`
+	Type   string `xml:"type,attr,omitempty"`
+	Length int64  `xml:"length,attr,omitempty"`
+}
+
+type AtomContent struct {
+	Type  string `xml:"type,attr"`
+	Value string `xml:",chardata"`
+}
+
+type AtomPerson struct {
+	Name  string `xml:"name"`
+	URI   string `xml:"uri,omitempty"`
+	Email string `xml:"email,omitempty"`
+}
+
+type AtomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+type AtomEntry struct {
+	Title       string          `xml:"title"`
+	Id          string          `xml:"id"`
+	Links       []*AtomLink     `xml:"link"`
+	Updated     string          `xml:"updated"`
+	Published   string          `xml:"published,omitempty"`
+	Summary     string          `xml:"summary,omitempty"`
+	Content     *AtomContent    `xml:"content,omitempty"`
+	Authors     []*AtomPerson   `xml:"author"`
+	Categories  []*AtomCategory `xml:"category"`
+	GeoRSSPoint string          `xml:"georss:point,omitempty"`
+}
+
+// RenderAtom encodes docs as an Atom 1.0 document.
+
+func RenderAtom(docs []*markdown.Document, opts *FeedOptions) (io.ReadCloser, error) {
+
+	site := opts.Site
+	updated := feedUpdated(docs)
+
+	entries := make([]*AtomEntry, 0)
+
+	for _, d := range docs {
+
+		fm := d.FrontMatter
+		link := site.AbsoluteURL(fm.Permalink)
+
+		entry_updated := updated
+
+		if fm.Date != nil {
+			entry_updated = *fm.Date
+		}
+
+		authors := make([]*AtomPerson, 0)
+
+		for _, a := range feedAuthors(fm, opts) {
+
+			p, name := feedProfile(a, opts)
+			person := &AtomPerson{Name: name}
+
+			if p != nil {
+				person.URI = p.URL
+				person.Email = p.Email
+			}
+
+			authors = append(authors, person)
+		}
+
+		categories := make([]*AtomCategory, 0)
+
+		for _, t := range fm.Tags {
+			categories = append(categories, &AtomCategory{Term: t})
+		}
+
+		entry := AtomEntry{
+			Title: fm.Title,
+			Id:    link,
+			Links: []*AtomLink{
+				&AtomLink{Href: link, Rel: "alternate", Type: "text/html"},
+			},
+			Updated:    entry_updated.Format(time.RFC3339),
+			Summary:    fm.Excerpt,
+			Authors:    authors,
+			Categories: categories,
+		}
+
+		if fm.Date != nil {
+			entry.Published = fm.Date.Format(time.RFC3339)
+		}
+
+		if opts.FullContent {
+
+			content, err := RenderContent(d, site)
+
+			if err != nil {
+				return nil, err
+			}
+
+			entry.Content = &AtomContent{Type: "html", Value: content}
+		}
+
+		for _, e := range FeedEnclosures(d, site) {
+			entry.Links = append(entry.Links, &AtomLink{Href: e.URL, Rel: "enclosure", Type: e.Type, Length: e.Length})
+		}
+
+		if opts.GeoRSS {
+			entry.GeoRSSPoint = GeoRSSPoint(fm)
+		}
+
+		entries = append(entries, &entry)
+	}
+
+	links := []*AtomLink{
+		&AtomLink{Href: site.HomeURL(), Rel: "alternate", Type: "text/html"},
+	}
+
+	if opts.URL != "" {
+		links = append(links, &AtomLink{Href: site.AbsoluteURL(opts.URL), Rel: "self", Type: "application/atom+xml"})
+	}
+
+	authors := make([]*AtomPerson, 0)
+
+	if site.Author != "" {
+		authors = append(authors, &AtomPerson{Name: site.Author})
+	}
+
+	feed := Atom{
+		Namespace: AtomNamespace,
+		Title:     site.Title,
+		Subtitle:  site.Description,
+		Id:        site.FeedId(),
+		Updated:   updated.Format(time.RFC3339),
+		Links:     links,
+		Authors:   authors,
+		Generator: FeedGenerator,
+		Entries:   entries,
+	}
+
+	if opts.GeoRSS {
+		feed.GeoRSSNS = GeoRSSNamespace
+	}
+
+	return encodeXML(feed)
+}
@@ -0,0 +1,84 @@
+package render
+
+import (
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/russross/blackfriday/v2"
+	"github.com/whosonfirst/go-whosonfirst-markdown"
+)
+
+var re_html_url *regexp.Regexp
+
+func init() {
+	re_html_url = regexp.MustCompile(`(?i)((?:href|src)\s*=\s*)"([^"]*)"`)
+}
+
+// AbsoluteURLRenderer renders the body of a document as an HTML fragment,
+// rewriting relative links and images (including those in inline HTML) to be
+// absolute URLs relative to the document's permalink and the site's base URL.
+
+type AbsoluteURLRenderer struct {
+	bf        *blackfriday.HTMLRenderer
+	site      *SiteMetadata
+	permalink string
+}
+
+func (r *AbsoluteURLRenderer) RenderNode(w io.Writer, node *blackfriday.Node, entering bool) blackfriday.WalkStatus {
+
+	switch node.Type {
+
+	case blackfriday.Link, blackfriday.Image:
+
+		if entering {
+			dest := string(node.LinkData.Destination)
+			node.LinkData.Destination = []byte(r.site.AbsoluteURLFrom(r.permalink, dest))
+		}
+
+	case blackfriday.HTMLBlock, blackfriday.HTMLSpan:
+
+		node.Literal = re_html_url.ReplaceAllFunc(node.Literal, func(m []byte) []byte {
+			parts := re_html_url.FindSubmatch(m)
+			abs_url := r.site.AbsoluteURLFrom(r.permalink, string(parts[2]))
+			return []byte(string(parts[1]) + `"` + abs_url + `"`)
+		})
+	}
+
+	return r.bf.RenderNode(w, node, entering)
+}
+
+func (r *AbsoluteURLRenderer) RenderHeader(w io.Writer, ast *blackfriday.Node) {
+	return
+}
+
+func (r *AbsoluteURLRenderer) RenderFooter(w io.Writer, ast *blackfriday.Node) {
+	return
+}
+
+// RenderContent returns the body of d as an HTML fragment (no header, footer or
+// page chrome) suitable for including in a feed. It returns an empty string if d
+// has no body.
+
+func RenderContent(d *markdown.Document, site *SiteMetadata) (string, error) {
+
+	if d.Body == nil {
+		return "", nil
+	}
+
+	flags := blackfriday.CommonHTMLFlags
+	flags |= blackfriday.UseXHTML
+
+	params := blackfriday.HTMLRendererParameters{
+		Flags: flags,
+	}
+
+	r := AbsoluteURLRenderer{
+		bf:        blackfriday.NewHTMLRenderer(params),
+		site:      site,
+		permalink: d.FrontMatter.Permalink,
+	}
+
+	html := blackfriday.Run(d.Body.Bytes(), blackfriday.WithRenderer(&r))
+	return strings.TrimSpace(string(html)), nil
+}
@@ -0,0 +1,196 @@
+package render
+
+import (
+	"mime"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/whosonfirst/go-whosonfirst-markdown"
+)
+
+const ITunesNamespace = "http://www.itunes.com/dtds/podcast-1.0.dtd"
+
+// Go's built-in MIME types don't include most audio and video formats and we
+// can't count on the host system's mime.types file so...
+
+var media_types = map[string]string{
+	".flac": "audio/flac",
+	".m4a":  "audio/mp4",
+	".m4v":  "video/x-m4v",
+	".mov":  "video/quicktime",
+	".mp3":  "audio/mpeg",
+	".mp4":  "video/mp4",
+	".oga":  "audio/ogg",
+	".ogg":  "audio/ogg",
+	".ogv":  "video/ogg",
+	".opus": "audio/opus",
+	".wav":  "audio/wav",
+	".webm": "video/webm",
+}
+
+type FeedEnclosure struct {
+	URL      string
+	Length   int64
+	Type     string
+	Duration string
+}
+
+// Seconds returns the enclosure's duration in seconds, or 0 if it is not known.
+// Durations may be "HH:MM:SS", "MM:SS" or a number of seconds.
+
+func (e *FeedEnclosure) Seconds() int64 {
+
+	if e.Duration == "" {
+		return 0
+	}
+
+	seconds := int64(0)
+
+	for _, p := range strings.Split(e.Duration, ":") {
+
+		i, err := strconv.ParseInt(strings.TrimSpace(p), 10, 64)
+
+		if err != nil {
+			return 0
+		}
+
+		seconds = (seconds * 60) + i
+	}
+
+	return seconds
+}
+
+// ImageEnclosure returns an enclosure for the image defined in d's front matter,
+// or nil if there isn't one. Length is 0 if the image can't be found locally (see
+// localLength), in which case RSS, which requires a length, leaves it out.
+
+func ImageEnclosure(d *markdown.Document, site *SiteMetadata) *FeedEnclosure {
+
+	fm := d.FrontMatter
+
+	if fm.Image == "" {
+		return nil
+	}
+
+	e := FeedEnclosure{
+		URL:    site.AbsoluteURLFrom(fm.Permalink, fm.Image),
+		Length: localLength(d, site, fm.Image),
+		Type:   mimeType(fm.Image),
+	}
+
+	return &e
+}
+
+// MediaEnclosure returns an enclosure for the audio or video file defined in d's
+// front matter, or nil if there isn't one. Length and type are taken from the
+// front matter if present.
+
+func MediaEnclosure(d *markdown.Document, site *SiteMetadata) *FeedEnclosure {
+
+	fm := d.FrontMatter
+
+	if fm.Enclosure == nil || fm.Enclosure.URL == "" {
+		return nil
+	}
+
+	e := FeedEnclosure{
+		URL:      site.AbsoluteURLFrom(fm.Permalink, fm.Enclosure.URL),
+		Length:   fm.Enclosure.Length,
+		Type:     fm.Enclosure.Type,
+		Duration: fm.Enclosure.Duration,
+	}
+
+	if e.Length == 0 {
+		e.Length = localLength(d, site, fm.Enclosure.URL)
+	}
+
+	if e.Type == "" {
+		e.Type = mimeType(fm.Enclosure.URL)
+	}
+
+	return &e
+}
+
+// FeedEnclosures returns all the enclosures for d, media first.
+
+func FeedEnclosures(d *markdown.Document, site *SiteMetadata) []*FeedEnclosure {
+
+	enclosures := make([]*FeedEnclosure, 0)
+
+	for _, e := range []*FeedEnclosure{MediaEnclosure(d, site), ImageEnclosure(d, site)} {
+
+		if e != nil {
+			enclosures = append(enclosures, e)
+		}
+	}
+
+	return enclosures
+}
+
+// localLength returns the size of the file at ref, or 0 if it can not be found.
+// Site-absolute refs ("/images/x.jpg") are relative to the site's Root and
+// everything else to the directory containing d's source file. Remote files are
+// never fetched.
+
+func localLength(d *markdown.Document, site *SiteMetadata, ref string) int64 {
+
+	if strings.Contains(ref, "://") || strings.HasPrefix(ref, "//") {
+		return 0
+	}
+
+	if i := strings.IndexAny(ref, "?#"); i != -1 {
+		ref = ref[0:i]
+	}
+
+	var local_path string
+
+	if strings.HasPrefix(ref, "/") {
+
+		if site == nil || site.Root == "" {
+			return 0
+		}
+
+		local_path = filepath.Join(site.Root, filepath.FromSlash(ref))
+
+	} else {
+
+		if d.Path == "" {
+			return 0
+		}
+
+		local_path = filepath.Join(filepath.Dir(d.Path), filepath.FromSlash(ref))
+	}
+
+	info, err := os.Stat(local_path)
+
+	if err != nil || info.IsDir() {
+		return 0
+	}
+
+	return info.Size()
+}
+
+func mimeType(ref string) string {
+
+	ext := strings.ToLower(filepath.Ext(ref))
+
+	if i := strings.IndexAny(ext, "?#"); i != -1 {
+		ext = ext[0:i]
+	}
+
+	t, ok := media_types[ext]
+
+	if ok {
+		return t
+	}
+
+	t = mime.TypeByExtension(ext)
+
+	if t == "" {
+		t = "application/octet-stream"
+	}
+
+	return t
+}
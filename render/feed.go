@@ -1,26 +1,269 @@
 package render
 
 import (
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	url_path "path"
+	"sort"
+	"strings"
 	"text/template"
+	"time"
+
+	"github.com/whosonfirst/go-whosonfirst-markdown"
+	"github.com/whosonfirst/go-whosonfirst-markdown/authors"
+	"github.com/whosonfirst/go-whosonfirst-markdown/jekyll"
+	"github.com/whosonfirst/go-whosonfirst-markdown/places"
+	"github.com/whosonfirst/go-whosonfirst-markdown/publish"
+	"github.com/whosonfirst/go-whosonfirst-markdown/taxonomy"
 )
 
+const FeedGenerator = "go-whosonfirst-markdown"
+
 type FeedOptions struct {
-	Format    string
-	Input     string
-	Output    string
-	Items     int
-	Templates *template.Template
+	Mode            string
+	Format          string
+	Input           string
+	Output          string
+	URL             string
+	OPML            string
+	Items           int
+	FullContent     bool
+	GeoRSS          bool
+	Podcast         bool
+	PodcastCategory string
+	Validate        bool
+	Places          *places.LocalResolver
+	Taxonomies      *taxonomy.Taxonomies
+	Authors         *authors.Profiles
+	Filter          *publish.Filter
+	Site            *SiteMetadata
+	Templates       *template.Template
 }
 
 func DefaultFeedOptions() *FeedOptions {
 
 	opts := FeedOptions{
-		Input:     "index.md",
-		Format:    "rss_20",
-		Output:    "rss_20.xml",
-		Items:     10,
-		Templates: nil,
+		Mode:            "all",
+		OPML:            "feeds.opml",
+		Input:           "index.md",
+		Format:          "rss_20",
+		Output:          "rss_20.xml",
+		URL:             "",
+		Items:           10,
+		FullContent:     false,
+		GeoRSS:          false,
+		Podcast:         false,
+		PodcastCategory: "",
+		Validate:        false,
+		Places:          nil,
+		Taxonomies:      nil,
+		Authors:         nil,
+		Filter:          publish.DefaultFilter(),
+		Site:            DefaultSiteMetadata(),
+		Templates:       nil,
 	}
 
 	return &opts
 }
+
+// SiteMetadata describes the site a feed belongs to. BaseURL is used to make
+// permalinks absolute and Id, if empty, defaults to BaseURL.
+
+type SiteMetadata struct {
+	Title       string
+	Description string
+	BaseURL     string
+	Author      string
+	Image       string
+	Id          string
+	// the local directory that site-absolute paths ("/images/x.jpg") are
+	// relative to, used to find the length of enclosures
+	Root string
+}
+
+func DefaultSiteMetadata() *SiteMetadata {
+
+	s := SiteMetadata{
+		Title:       "",
+		Description: "",
+		BaseURL:     "",
+		Author:      "",
+		Image:       "",
+		Id:          "",
+	}
+
+	return &s
+}
+
+// AbsoluteURL resolves path relative to the site's base URL. If there is no base
+// URL or path can not be parsed then path is returned unchanged.
+
+func (s *SiteMetadata) AbsoluteURL(path string) string {
+
+	if s.BaseURL == "" {
+		return path
+	}
+
+	base, err := url.Parse(s.BaseURL)
+
+	if err != nil {
+		return path
+	}
+
+	rel, err := url.Parse(path)
+
+	if err != nil {
+		return path
+	}
+
+	return base.ResolveReference(rel).String()
+}
+
+// AbsoluteURLFrom resolves path relative to permalink (for example an image
+// referenced by a post) and then relative to the site's base URL. Permalinks
+// without a file extension are pages in their own directory so they are treated
+// as directories, whether or not they end with a "/".
+
+func (s *SiteMetadata) AbsoluteURLFrom(permalink string, path string) string {
+
+	base, err := url.Parse(permalink)
+
+	if err != nil {
+		return s.AbsoluteURL(path)
+	}
+
+	if base.Path != "" && !strings.HasSuffix(base.Path, "/") && url_path.Ext(base.Path) == "" {
+		base.Path = base.Path + "/"
+	}
+
+	rel, err := url.Parse(path)
+
+	if err != nil {
+		return s.AbsoluteURL(path)
+	}
+
+	return s.AbsoluteURL(base.ResolveReference(rel).String())
+}
+
+// HomeURL returns the site's base URL, or "/" if it is not defined.
+
+func (s *SiteMetadata) HomeURL() string {
+
+	if s.BaseURL == "" {
+		return "/"
+	}
+
+	return s.BaseURL
+}
+
+func (s *SiteMetadata) FeedId() string {
+
+	if s.Id != "" {
+		return s.Id
+	}
+
+	return s.BaseURL
+}
+
+// FeedExtension returns the default file extension for a feed format.
+
+func FeedExtension(format string) string {
+
+	switch format {
+	case "geojson":
+		return "geojson"
+	case "jsonfeed_11":
+		return "json"
+	default:
+		return "xml"
+	}
+}
+
+// RenderFeed encodes docs (which are expected to be sorted) as a feed in the
+// format defined by opts.Format. Valid formats are: atom_10, jsonfeed_11, rss_20.
+
+func RenderFeed(docs []*markdown.Document, opts *FeedOptions) (io.ReadCloser, error) {
+
+	if opts.Site == nil {
+		opts.Site = DefaultSiteMetadata()
+	}
+
+	switch opts.Format {
+	case "atom_10":
+		return RenderAtom(docs, opts)
+	case "jsonfeed_11":
+		return RenderJSONFeed(docs, opts)
+	case "rss_20":
+		return RenderRSS(docs, opts)
+	default:
+		return nil, errors.New(fmt.Sprintf("Invalid or unsupported feed format '%s'", opts.Format))
+	}
+}
+
+// feedUpdated returns the most recent post date or, failing that, the current time.
+
+func feedUpdated(docs []*markdown.Document) time.Time {
+
+	dates := make([]time.Time, 0)
+
+	for _, d := range docs {
+
+		if d.FrontMatter.Date != nil {
+			dates = append(dates, *d.FrontMatter.Date)
+		}
+	}
+
+	if len(dates) == 0 {
+		return time.Now()
+	}
+
+	sort.Slice(dates, func(i, j int) bool {
+		return dates[i].After(dates[j])
+	})
+
+	return dates[0]
+}
+
+func feedAuthors(fm *jekyll.FrontMatter, opts *FeedOptions) []string {
+
+	if len(fm.Authors) > 0 {
+		return fm.Authors
+	}
+
+	if opts.Site.Author != "" {
+		return []string{opts.Site.Author}
+	}
+
+	return []string{}
+}
+
+// feedProfile returns the profile for an author, which may be nil, and the name
+// to use for them in a feed.
+
+func feedProfile(name string, opts *FeedOptions) (*authors.Profile, string) {
+
+	p := opts.Authors.Profile(name)
+
+	if p == nil {
+		return nil, name
+	}
+
+	return p, p.Name
+}
+
+// feedAuthorNames returns the names of the authors of fm using the names in their
+// profiles, where known.
+
+func feedAuthorNames(fm *jekyll.FrontMatter, opts *FeedOptions) []string {
+
+	names := make([]string, 0)
+
+	for _, a := range feedAuthors(fm, opts) {
+		_, name := feedProfile(a, opts)
+		names = append(names, name)
+	}
+
+	return names
+}
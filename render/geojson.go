@@ -0,0 +1,93 @@
+package render
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"time"
+
+	"github.com/whosonfirst/go-whosonfirst-markdown"
+)
+
+type GeoJSONFeatureCollection struct {
+	Type     string            `json:"type"`
+	Features []*GeoJSONFeature `json:"features"`
+}
+
+type GeoJSONFeature struct {
+	Type       string                 `json:"type"`
+	Geometry   *GeoJSONGeometry       `json:"geometry"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+type GeoJSONGeometry struct {
+	Type        string    `json:"type"`
+	Coordinates []float64 `json:"coordinates"`
+}
+
+// RenderGeoJSON returns a GeoJSON FeatureCollection with one (Point) feature for
+// each post that has coordinates. Posts without coordinates are skipped.
+
+func RenderGeoJSON(docs []*markdown.Document, opts *FeedOptions) (io.ReadCloser, error) {
+
+	site := opts.Site
+
+	if site == nil {
+		site = DefaultSiteMetadata()
+	}
+
+	features := make([]*GeoJSONFeature, 0)
+
+	for _, d := range docs {
+
+		fm := d.FrontMatter
+
+		if fm.Coordinates == nil {
+			continue
+		}
+
+		geom := GeoJSONGeometry{
+			Type: "Point",
+			Coordinates: []float64{
+				fm.Coordinates.Longitude,
+				fm.Coordinates.Latitude,
+			},
+		}
+
+		props := map[string]interface{}{
+			"title":     fm.Title,
+			"permalink": site.AbsoluteURL(fm.Permalink),
+			"excerpt":   fm.Excerpt,
+		}
+
+		if fm.Date != nil {
+			props["date"] = fm.Date.Format(time.RFC3339)
+		}
+
+		if len(fm.Places) > 0 {
+			props["places"] = fm.Places
+		}
+
+		f := GeoJSONFeature{
+			Type:       "Feature",
+			Geometry:   &geom,
+			Properties: props,
+		}
+
+		features = append(features, &f)
+	}
+
+	fc := GeoJSONFeatureCollection{
+		Type:     "FeatureCollection",
+		Features: features,
+	}
+
+	body, err := json.Marshal(fc)
+
+	if err != nil {
+		return nil, err
+	}
+
+	r := bytes.NewReader(body)
+	return nopCloser{r}, nil
+}
@@ -0,0 +1,21 @@
+package render
+
+import (
+	"fmt"
+
+	"github.com/whosonfirst/go-whosonfirst-markdown/jekyll"
+)
+
+const GeoRSSNamespace = "http://www.georss.org/georss"
+
+// GeoRSSPoint returns the value of a GeoRSS (simple) <georss:point> element for
+// a post, or an empty string if the post has no coordinates.
+
+func GeoRSSPoint(fm *jekyll.FrontMatter) string {
+
+	if fm.Coordinates == nil {
+		return ""
+	}
+
+	return fmt.Sprintf("%f %f", fm.Coordinates.Latitude, fm.Coordinates.Longitude)
+}
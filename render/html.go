@@ -5,11 +5,15 @@ import (
 	"html/template"
 	"io"
 	"log"
+	"path/filepath"
+	"strings"
 
 	"github.com/russross/blackfriday/v2"
 	"github.com/whosonfirst/go-whosonfirst-markdown"
+	"github.com/whosonfirst/go-whosonfirst-markdown/authors"
 	"github.com/whosonfirst/go-whosonfirst-markdown/jekyll"
-	
+	"github.com/whosonfirst/go-whosonfirst-markdown/nav"
+	"github.com/whosonfirst/go-whosonfirst-markdown/publish"
 )
 
 type HTMLOptions struct {
@@ -20,6 +24,8 @@ type HTMLOptions struct {
 	Footer    string
 	List      string
 	Title     string
+	Authors   *authors.Profiles
+	Filter    *publish.Filter
 	Templates *template.Template
 }
 
@@ -32,22 +38,102 @@ func DefaultHTMLOptions() *HTMLOptions {
 		Header:    "",
 		Footer:    "",
 		List:      "",
+		Authors:   nil,
+		Filter:    publish.DefaultFilter(),
 		Templates: nil,
 	}
 
 	return &opts
 }
 
+// HTMLOutputPath returns the path that the HTML for the document at abs_path is
+// written to: its permalink or, failing that, opts.Output in the same directory as
+// the document. If root is not empty the path is relative to root.
+
+func HTMLOutputPath(fm *jekyll.FrontMatter, abs_path string, root string, opts *HTMLOptions) string {
+
+	// I don't love that all this logic is here but I am not
+	// sure where else to put it... (20180109/thisisaaronland)
+
+	out_path := fm.Permalink
+
+	if out_path == "" {
+		abs_root := filepath.Dir(abs_path)
+		out_path = filepath.Join(abs_root, opts.Output)
+	}
+
+	if strings.HasSuffix(out_path, "/") {
+		out_path = filepath.Join(out_path, opts.Output)
+	}
+
+	if root != "" {
+		out_path = strings.Replace(out_path, root, "", -1)
+	}
+
+	return out_path
+}
+
+// PageContext is what header and footer templates are passed. It embeds the front
+// matter of the document being rendered so templates can continue to use {{ .Title }}
+// and so on. Profiles are the profiles for the document's authors, where known, and
+// Author is the author a page is about (for example a wof-md2idx author page). Prev,
+// Next, RelatedByTags and SameSeries are only defined if the document was rendered
+// with site navigation (see SetNavigation). Series is defined for any document with
+// "series" front matter but only includes the other parts with site navigation.
+
+type PageContext struct {
+	*jekyll.FrontMatter
+	Profiles      []*authors.Profile
+	Author        *authors.Profile
+	Prev          *jekyll.FrontMatter
+	Next          *jekyll.FrontMatter
+	RelatedByTags []*jekyll.FrontMatter
+	SameSeries    []*jekyll.FrontMatter
+	Series        *nav.Series
+}
+
+// NewPageContext returns a PageContext for d using the author profiles in opts
+
+func NewPageContext(d *markdown.Document, opts *HTMLOptions) *PageContext {
+
+	c := PageContext{
+		FrontMatter: d.FrontMatter,
+		Profiles:    opts.Authors.ProfilesForNames(d.FrontMatter.Authors),
+		Series:      nav.NewSeries(d.FrontMatter),
+	}
+
+	return &c
+}
+
+// SetNavigation assigns the previous, next, related and same series posts from n
+// which may be nil.
+
+func (c *PageContext) SetNavigation(n *nav.Context) {
+
+	if n == nil {
+		return
+	}
+
+	c.Prev = n.Prev
+	c.Next = n.Next
+	c.RelatedByTags = n.RelatedByTags
+	c.SameSeries = n.SameSeries
+
+	if n.Series != nil {
+		c.Series = n.Series
+	}
+}
+
 type nopCloser struct {
 	io.Reader
 }
 
 type WOFRenderer struct {
-	bf          *blackfriday.HTMLRenderer
-	frontmatter *jekyll.FrontMatter
-	header      string
-	footer      string
-	templates   *template.Template
+	bf        *blackfriday.HTMLRenderer
+	context   *PageContext
+	header    string
+	footer    string
+	templates *template.Template
 }
 
 func (r *WOFRenderer) RenderNode(w io.Writer, node *blackfriday.Node, entering bool) blackfriday.WalkStatus {
@@ -75,7 +161,7 @@ func (r *WOFRenderer) RenderHeader(w io.Writer, ast *blackfriday.Node) {
 		return
 	}
 
-	err := t.Execute(w, r.frontmatter)
+	err := t.Execute(w, r.context)
 
 	if err != nil {
 		log.Println(err)
@@ -96,7 +182,7 @@ func (r *WOFRenderer) RenderFooter(w io.Writer, ast *blackfriday.Node) {
 		return
 	}
 
-	err := t.Execute(w, r.frontmatter)
+	err := t.Execute(w, r.context)
 
 	if err != nil {
 		log.Println(err)
@@ -106,6 +192,13 @@ func (r *WOFRenderer) RenderFooter(w io.Writer, ast *blackfriday.Node) {
 func (nopCloser) Close() error { return nil }
 
 func RenderHTML(d *markdown.Document, opts *HTMLOptions) (io.ReadCloser, error) {
+	return RenderHTMLWithContext(d, opts, NewPageContext(d, opts))
+}
+
+// RenderHTMLWithContext renders d passing c (rather than a context derived from d
+// and opts) to header and footer templates.
+
+func RenderHTMLWithContext(d *markdown.Document, opts *HTMLOptions, c *PageContext) (io.ReadCloser, error) {
 
 	flags := blackfriday.CommonHTMLFlags
 	flags |= blackfriday.CompletePage
@@ -113,16 +206,17 @@ func RenderHTML(d *markdown.Document, opts *HTMLOptions) (io.ReadCloser, error)
 
 	params := blackfriday.HTMLRendererParameters{
 		Flags: flags,
+		Title: d.FrontMatter.Title,
 	}
 
 	renderer := blackfriday.NewHTMLRenderer(params)
 
 	r := WOFRenderer{
-		bf:          renderer,
-		frontmatter: d.FrontMatter,
-		header:      opts.Header,
-		footer:      opts.Footer,
-		templates:   opts.Templates,
+		bf:        renderer,
+		context:   c,
+		header:    opts.Header,
+		footer:    opts.Footer,
+		templates: opts.Templates,
 	}
 
 	unsafe := blackfriday.Run(d.Body.Bytes(), blackfriday.WithRenderer(&r))
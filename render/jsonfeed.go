@@ -0,0 +1,164 @@
+package render
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"time"
+
+	"github.com/whosonfirst/go-whosonfirst-markdown"
+)
+
+const JSONFeedVersion = "https://jsonfeed.org/version/1.1"
+
+type JSONFeed struct {
+	Version     string            `json:"version"`
+	Title       string            `json:"title"`
+	HomePageURL string            `json:"home_page_url,omitempty"`
+	FeedURL     string            `json:"feed_url,omitempty"`
+	Description string            `json:"description,omitempty"`
+	Authors     []*JSONFeedAuthor `json:"authors,omitempty"`
+	Items       []*JSONFeedItem   `json:"items"`
+}
+
+type JSONFeedAuthor struct {
+	Name   string `json:"name"`
+	URL    string `json:"url,omitempty"`
+	Avatar string `json:"avatar,omitempty"`
+}
+
+type JSONFeedItem struct {
+	Id            string                `json:"id"`
+	URL           string                `json:"url,omitempty"`
+	Title         string                `json:"title,omitempty"`
+	ContentHTML   string                `json:"content_html,omitempty"`
+	ContentText   *string               `json:"content_text,omitempty"`
+	Summary       string                `json:"summary,omitempty"`
+	Image         string                `json:"image,omitempty"`
+	DatePublished string                `json:"date_published,omitempty"`
+	Authors       []*JSONFeedAuthor     `json:"authors,omitempty"`
+	Tags          []string              `json:"tags,omitempty"`
+	Attachments   []*JSONFeedAttachment `json:"attachments,omitempty"`
+}
+
+type JSONFeedAttachment struct {
+	URL               string `json:"url"`
+	MimeType          string `json:"mime_type"`
+	SizeInBytes       int64  `json:"size_in_bytes,omitempty"`
+	DurationInSeconds int64  `json:"duration_in_seconds,omitempty"`
+}
+
+// RenderJSONFeed encodes docs as a JSON Feed (1.1) document.
+
+func RenderJSONFeed(docs []*markdown.Document, opts *FeedOptions) (io.ReadCloser, error) {
+
+	site := opts.Site
+
+	items := make([]*JSONFeedItem, 0)
+
+	for _, d := range docs {
+
+		fm := d.FrontMatter
+		link := site.AbsoluteURL(fm.Permalink)
+
+		authors := make([]*JSONFeedAuthor, 0)
+
+		for _, a := range feedAuthors(fm, opts) {
+
+			p, name := feedProfile(a, opts)
+			author := &JSONFeedAuthor{Name: name}
+
+			if p != nil {
+				author.URL = p.URL
+
+				if p.Avatar != "" {
+					author.Avatar = site.AbsoluteURL(p.Avatar)
+				}
+			}
+
+			authors = append(authors, author)
+		}
+
+		item := JSONFeedItem{
+			Id:      link,
+			URL:     link,
+			Title:   fm.Title,
+			Summary: fm.Excerpt,
+			Authors: authors,
+			Tags:    fm.Tags,
+		}
+
+		if fm.Image != "" {
+			item.Image = site.AbsoluteURLFrom(fm.Permalink, fm.Image)
+		}
+
+		if fm.Date != nil {
+			item.DatePublished = fm.Date.Format(time.RFC3339)
+		}
+
+		for _, e := range FeedEnclosures(d, site) {
+
+			a := JSONFeedAttachment{
+				URL:               e.URL,
+				MimeType:          e.Type,
+				SizeInBytes:       e.Length,
+				DurationInSeconds: e.Seconds(),
+			}
+
+			item.Attachments = append(item.Attachments, &a)
+		}
+
+		if opts.FullContent {
+
+			content, err := RenderContent(d, site)
+
+			if err != nil {
+				return nil, err
+			}
+
+			item.ContentHTML = content
+		}
+
+		// JSON Feed items must have one of content_html or content_text
+
+		if item.ContentHTML == "" {
+			text := fm.Excerpt
+			item.ContentText = &text
+		}
+
+		items = append(items, &item)
+	}
+
+	feed := JSONFeed{
+		Version:     JSONFeedVersion,
+		Title:       site.Title,
+		HomePageURL: site.HomeURL(),
+		Description: site.Description,
+		Items:       items,
+	}
+
+	if opts.URL != "" {
+		feed.FeedURL = site.AbsoluteURL(opts.URL)
+	}
+
+	if site.Author != "" {
+		feed.Authors = []*JSONFeedAuthor{
+			&JSONFeedAuthor{Name: site.Author},
+		}
+	}
+
+	var b bytes.Buffer
+
+	enc := json.NewEncoder(&b)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+
+	err := enc.Encode(feed)
+
+	if err != nil {
+		return nil, err
+	}
+
+	r := bytes.NewReader(b.Bytes())
+	return nopCloser{r}, nil
+}
@@ -0,0 +1,66 @@
+package render
+
+import (
+	"encoding/xml"
+	"io"
+	"time"
+)
+
+type OPML struct {
+	XMLName xml.Name  `xml:"opml"`
+	Version string    `xml:"version,attr"`
+	Head    *OPMLHead `xml:"head"`
+	Body    *OPMLBody `xml:"body"`
+}
+
+type OPMLHead struct {
+	Title       string `xml:"title"`
+	DateCreated string `xml:"dateCreated"`
+}
+
+type OPMLBody struct {
+	Outlines []*OPMLOutline `xml:"outline"`
+}
+
+type OPMLOutline struct {
+	Type    string `xml:"type,attr"`
+	Text    string `xml:"text,attr"`
+	Title   string `xml:"title,attr,omitempty"`
+	XMLURL  string `xml:"xmlUrl,attr"`
+	HTMLURL string `xml:"htmlUrl,attr,omitempty"`
+}
+
+// OPMLType returns the value of the type attribute of an outline for a feed in
+// format, for example "atom" for atom_10 feeds.
+
+func OPMLType(format string) string {
+
+	switch format {
+	case "atom_10":
+		return "atom"
+	case "jsonfeed_11":
+		return "jsonfeed"
+	case "geojson":
+		return "geojson"
+	default:
+		return "rss"
+	}
+}
+
+// RenderOPML encodes a list of feeds as an OPML 2.0 subscription list.
+
+func RenderOPML(title string, outlines []*OPMLOutline) (io.ReadCloser, error) {
+
+	doc := OPML{
+		Version: "2.0",
+		Head: &OPMLHead{
+			Title:       title,
+			DateCreated: time.Now().Format(time.RFC1123Z),
+		},
+		Body: &OPMLBody{
+			Outlines: outlines,
+		},
+	}
+
+	return encodeXML(doc)
+}
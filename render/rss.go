@@ -0,0 +1,220 @@
+package render
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/whosonfirst/go-whosonfirst-markdown"
+)
+
+const RSSContentNamespace = "http://purl.org/rss/1.0/modules/content/"
+
+type RSS struct {
+	XMLName    xml.Name    `xml:"rss"`
+	Version    string      `xml:"version,attr"`
+	AtomNS     string      `xml:"xmlns:atom,attr"`
+	DublinCore string      `xml:"xmlns:dc,attr"`
+	ContentNS  string      `xml:"xmlns:content,attr,omitempty"`
+	GeoRSSNS   string      `xml:"xmlns:georss,attr,omitempty"`
+	ITunesNS   string      `xml:"xmlns:itunes,attr,omitempty"`
+	Channel    *RSSChannel `xml:"channel"`
+}
+
+type RSSChannel struct {
+	Title          string          `xml:"title"`
+	Link           string          `xml:"link"`
+	Description    string          `xml:"description"`
+	AtomLink       *AtomLink       `xml:"atom:link,omitempty"`
+	LastBuildDate  string          `xml:"lastBuildDate"`
+	Generator      string          `xml:"generator"`
+	ITunesAuthor   string          `xml:"itunes:author,omitempty"`
+	ITunesSummary  string          `xml:"itunes:summary,omitempty"`
+	ITunesImage    *ITunesImage    `xml:"itunes:image,omitempty"`
+	ITunesCategory *ITunesCategory `xml:"itunes:category,omitempty"`
+	ITunesExplicit string          `xml:"itunes:explicit,omitempty"`
+	Items          []*RSSItem      `xml:"item"`
+}
+
+type RSSItem struct {
+	Title          string        `xml:"title"`
+	Link           string        `xml:"link"`
+	GUID           *RSSGUID      `xml:"guid"`
+	PubDate        string        `xml:"pubDate,omitempty"`
+	Description    string        `xml:"description,omitempty"`
+	Content        string        `xml:"content:encoded,omitempty"`
+	Creators       []string      `xml:"dc:creator"`
+	Categories     []string      `xml:"category"`
+	Enclosure      *RSSEnclosure `xml:"enclosure,omitempty"`
+	GeoRSSPoint    string        `xml:"georss:point,omitempty"`
+	ITunesAuthor   string        `xml:"itunes:author,omitempty"`
+	ITunesSummary  string        `xml:"itunes:summary,omitempty"`
+	ITunesDuration string        `xml:"itunes:duration,omitempty"`
+	ITunesImage    *ITunesImage  `xml:"itunes:image,omitempty"`
+}
+
+type ITunesImage struct {
+	Href string `xml:"href,attr"`
+}
+
+type ITunesCategory struct {
+	Text string `xml:"text,attr"`
+}
+
+type RSSEnclosure struct {
+	URL    string `xml:"url,attr"`
+	Length int64  `xml:"length,attr"`
+	Type   string `xml:"type,attr"`
+}
+
+type RSSGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+// RenderRSS encodes docs as an RSS 2.0 document.
+
+func RenderRSS(docs []*markdown.Document, opts *FeedOptions) (io.ReadCloser, error) {
+
+	site := opts.Site
+
+	items := make([]*RSSItem, 0)
+
+	for _, d := range docs {
+
+		fm := d.FrontMatter
+		link := site.AbsoluteURL(fm.Permalink)
+
+		item := RSSItem{
+			Title: fm.Title,
+			Link:  link,
+			GUID: &RSSGUID{
+				IsPermaLink: site.BaseURL != "",
+				Value:       link,
+			},
+			Description: fm.Excerpt,
+			Creators:    feedAuthorNames(fm, opts),
+			Categories:  fm.Tags,
+		}
+
+		if fm.Date != nil {
+			item.PubDate = fm.Date.Format(time.RFC1123Z)
+		}
+
+		if opts.FullContent {
+
+			content, err := RenderContent(d, site)
+
+			if err != nil {
+				return nil, err
+			}
+
+			item.Content = content
+		}
+
+		// RSS only allows one enclosure per item so media (audio, video) wins,
+		// unless its length isn't known since RSS requires one
+
+		for _, e := range FeedEnclosures(d, site) {
+
+			if e.Length > 0 {
+				item.Enclosure = &RSSEnclosure{URL: e.URL, Length: e.Length, Type: e.Type}
+				break
+			}
+		}
+
+		if opts.Podcast {
+
+			item.ITunesAuthor = strings.Join(feedAuthorNames(fm, opts), ", ")
+			item.ITunesSummary = fm.Excerpt
+
+			if fm.Enclosure != nil {
+				item.ITunesDuration = fm.Enclosure.Duration
+			}
+
+			if fm.Image != "" {
+				item.ITunesImage = &ITunesImage{Href: site.AbsoluteURLFrom(fm.Permalink, fm.Image)}
+			}
+		}
+
+		if opts.GeoRSS {
+			item.GeoRSSPoint = GeoRSSPoint(fm)
+		}
+
+		items = append(items, &item)
+	}
+
+	channel := RSSChannel{
+		Title:         site.Title,
+		Link:          site.HomeURL(),
+		Description:   site.Description,
+		LastBuildDate: feedUpdated(docs).Format(time.RFC1123Z),
+		Generator:     FeedGenerator,
+		Items:         items,
+	}
+
+	if opts.URL != "" {
+
+		channel.AtomLink = &AtomLink{
+			Href: site.AbsoluteURL(opts.URL),
+			Rel:  "self",
+			Type: "application/rss+xml",
+		}
+	}
+
+	rss := RSS{
+		Version:    "2.0",
+		AtomNS:     AtomNamespace,
+		DublinCore: "http://purl.org/dc/elements/1.1/",
+		Channel:    &channel,
+	}
+
+	if opts.FullContent {
+		rss.ContentNS = RSSContentNamespace
+	}
+
+	if opts.GeoRSS {
+		rss.GeoRSSNS = GeoRSSNamespace
+	}
+
+	if opts.Podcast {
+
+		rss.ITunesNS = ITunesNamespace
+
+		channel.ITunesAuthor = site.Author
+		channel.ITunesSummary = site.Description
+		channel.ITunesExplicit = "false"
+
+		if site.Image != "" {
+			channel.ITunesImage = &ITunesImage{Href: site.AbsoluteURL(site.Image)}
+		}
+
+		if opts.PodcastCategory != "" {
+			channel.ITunesCategory = &ITunesCategory{Text: opts.PodcastCategory}
+		}
+	}
+
+	return encodeXML(rss)
+}
+
+func encodeXML(doc interface{}) (io.ReadCloser, error) {
+
+	var b bytes.Buffer
+	b.WriteString(xml.Header)
+
+	enc := xml.NewEncoder(&b)
+	enc.Indent("", "  ")
+
+	err := enc.Encode(doc)
+
+	if err != nil {
+		return nil, err
+	}
+
+	b.WriteString("\n")
+
+	r := bytes.NewReader(b.Bytes())
+	return nopCloser{r}, nil
+}
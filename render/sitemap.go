@@ -0,0 +1,252 @@
+package render
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/whosonfirst/go-whosonfirst-markdown/publish"
+)
+
+const SitemapNamespace = "http://www.sitemaps.org/schemas/sitemap/0.9"
+
+// the maximum number of URLs allowed in a single sitemap, per sitemaps.org
+
+const SitemapMaxURLs = 50000
+
+type SitemapOptions struct {
+	Input      string
+	Output     string
+	MaxURLs    int
+	Priority   float64
+	Priorities []*SitemapPriority
+	Site       *SiteMetadata
+	Filter     *publish.Filter
+}
+
+func DefaultSitemapOptions() *SitemapOptions {
+
+	opts := SitemapOptions{
+		Input:      "index.md",
+		Output:     "sitemap.xml",
+		MaxURLs:    SitemapMaxURLs,
+		Priority:   -1.0,
+		Priorities: make([]*SitemapPriority, 0),
+		Site:       DefaultSiteMetadata(),
+		Filter:     publish.DefaultFilter(),
+	}
+
+	return &opts
+}
+
+// SitemapPriority assigns Priority to any URL whose path matches Pattern.
+
+type SitemapPriority struct {
+	Pattern  *regexp.Regexp
+	Priority float64
+}
+
+// PriorityForPath returns the priority of the first rule matching path, or the
+// default priority (which may be < 0, meaning no priority is assigned).
+
+func (opts *SitemapOptions) PriorityForPath(path string) float64 {
+
+	for _, p := range opts.Priorities {
+
+		if p.Pattern.MatchString(path) {
+			return p.Priority
+		}
+	}
+
+	return opts.Priority
+}
+
+type SitemapURL struct {
+	Loc      string   `xml:"loc"`
+	LastMod  string   `xml:"lastmod,omitempty"`
+	Priority *float64 `xml:"priority,omitempty"`
+}
+
+type Sitemap struct {
+	XMLName   xml.Name      `xml:"urlset"`
+	Namespace string        `xml:"xmlns,attr"`
+	URLs      []*SitemapURL `xml:"url"`
+}
+
+type SitemapIndexEntry struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod,omitempty"`
+}
+
+type SitemapIndex struct {
+	XMLName   xml.Name             `xml:"sitemapindex"`
+	Namespace string               `xml:"xmlns,attr"`
+	Sitemaps  []*SitemapIndexEntry `xml:"sitemap"`
+}
+
+// SitemapFile is a single (rendered) file to write; Path is relative to the
+// directory the sitemap is being written to.
+
+type SitemapFile struct {
+	Path string
+	Body io.ReadCloser
+}
+
+// NewSitemapURL returns a SitemapURL for path, made absolute using the site's base
+// URL and with a priority assigned using opts.Priorities.
+
+func NewSitemapURL(path string, lastmod *time.Time, opts *SitemapOptions) *SitemapURL {
+
+	u := SitemapURL{
+		Loc: opts.Site.AbsoluteURL(path),
+	}
+
+	if lastmod != nil {
+		u.LastMod = lastmod.UTC().Format(time.RFC3339)
+	}
+
+	p := opts.PriorityForPath(path)
+
+	if p >= 0.0 {
+		u.Priority = &p
+	}
+
+	return &u
+}
+
+// RenderSitemaps returns the file(s) for a list of URLs. If there are more than
+// opts.MaxURLs then the URLs are split across multiple sitemaps (sitemap-1.xml,
+// sitemap-2.xml, etc. given an Output of sitemap.xml) and opts.Output becomes a
+// sitemap index.
+
+func RenderSitemaps(urls []*SitemapURL, opts *SitemapOptions) ([]*SitemapFile, error) {
+
+	max := opts.MaxURLs
+
+	if max <= 0 || max > SitemapMaxURLs {
+		max = SitemapMaxURLs
+	}
+
+	if len(urls) <= max {
+
+		fh, err := RenderSitemap(urls)
+
+		if err != nil {
+			return nil, err
+		}
+
+		f := SitemapFile{
+			Path: opts.Output,
+			Body: fh,
+		}
+
+		return []*SitemapFile{&f}, nil
+	}
+
+	files := make([]*SitemapFile, 0)
+	entries := make([]*SitemapIndexEntry, 0)
+
+	ext := filepath.Ext(opts.Output)
+	prefix := strings.TrimSuffix(opts.Output, ext)
+
+	for i := 0; i*max < len(urls); i++ {
+
+		start := i * max
+		end := start + max
+
+		if end > len(urls) {
+			end = len(urls)
+		}
+
+		chunk := urls[start:end]
+
+		fh, err := RenderSitemap(chunk)
+
+		if err != nil {
+			return nil, err
+		}
+
+		path := fmt.Sprintf("%s-%d%s", prefix, i+1, ext)
+
+		files = append(files, &SitemapFile{Path: path, Body: fh})
+
+		e := SitemapIndexEntry{
+			Loc:     opts.Site.AbsoluteURL(path),
+			LastMod: latestLastMod(chunk),
+		}
+
+		entries = append(entries, &e)
+	}
+
+	idx := SitemapIndex{
+		Namespace: SitemapNamespace,
+		Sitemaps:  entries,
+	}
+
+	fh, err := encodeXML(idx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	files = append(files, &SitemapFile{Path: opts.Output, Body: fh})
+	return files, nil
+}
+
+func RenderSitemap(urls []*SitemapURL) (io.ReadCloser, error) {
+
+	sm := Sitemap{
+		Namespace: SitemapNamespace,
+		URLs:      urls,
+	}
+
+	return encodeXML(sm)
+}
+
+// RenderRobots returns a robots.txt file allowing everything except disallow and
+// pointing to the sitemap at sitemap_url.
+
+func RenderRobots(sitemap_url string, disallow []string) (io.ReadCloser, error) {
+
+	lines := []string{
+		"User-agent: *",
+	}
+
+	if len(disallow) == 0 {
+		lines = append(lines, "Disallow:")
+	}
+
+	for _, path := range disallow {
+		lines = append(lines, fmt.Sprintf("Disallow: %s", path))
+	}
+
+	if sitemap_url != "" {
+		lines = append(lines, "")
+		lines = append(lines, fmt.Sprintf("Sitemap: %s", sitemap_url))
+	}
+
+	body := strings.Join(lines, "\n") + "\n"
+
+	r := strings.NewReader(body)
+	return nopCloser{r}, nil
+}
+
+func latestLastMod(urls []*SitemapURL) string {
+
+	// RFC 3339 dates in the same timezone (UTC, see NewSitemapURL) sort lexically
+
+	latest := ""
+
+	for _, u := range urls {
+
+		if u.LastMod > latest {
+			latest = u.LastMod
+		}
+	}
+
+	return latest
+}
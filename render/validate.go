@@ -0,0 +1,517 @@
+package render
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FeedValidation is the result of validating a feed. Format is one of atom_10,
+// jsonfeed_11 or rss_20, or empty if the format could not be determined. Warnings
+// are things that are allowed but probably not intended (for example RSS items
+// without a <guid>) and don't make a feed invalid.
+
+type FeedValidation struct {
+	Format   string
+	Problems []string
+	Warnings []string
+}
+
+func (v *FeedValidation) Valid() bool {
+	return len(v.Problems) == 0
+}
+
+func (v *FeedValidation) addProblem(msg string, args ...interface{}) {
+	v.Problems = append(v.Problems, fmt.Sprintf(msg, args...))
+}
+
+func (v *FeedValidation) addWarning(msg string, args ...interface{}) {
+	v.Warnings = append(v.Warnings, fmt.Sprintf(msg, args...))
+}
+
+// ValidateFeed reads an RSS 2.0, Atom 1.0 or JSON Feed document and checks that it
+// is well-formed, has all its required elements, uses valid dates, has unique
+// GUIDs (or ids) and only absolute links. GUIDs are optional in RSS 2.0 so items
+// without one are only warned about. An error is only returned if fh can not
+// be read; problems with the feed itself are reported in FeedValidation.Problems.
+
+func ValidateFeed(fh io.Reader) (*FeedValidation, error) {
+
+	body, err := ioutil.ReadAll(fh)
+
+	if err != nil {
+		return nil, err
+	}
+
+	v := &FeedValidation{
+		Problems: make([]string, 0),
+		Warnings: make([]string, 0),
+	}
+
+	trimmed := bytes.TrimSpace(body)
+
+	if len(trimmed) == 0 {
+		v.addProblem("Feed is empty")
+		return v, nil
+	}
+
+	if trimmed[0] == '{' {
+		v.Format = "jsonfeed_11"
+		validateJSONFeed(trimmed, v)
+		return v, nil
+	}
+
+	root, err := xmlRootElement(trimmed)
+
+	if err != nil {
+		v.addProblem("Feed is not well-formed XML: %v", err)
+		return v, nil
+	}
+
+	switch {
+	case root.Local == "rss":
+		v.Format = "rss_20"
+		validateRSS(trimmed, v)
+	case root.Local == "feed" && root.Space == AtomNamespace:
+		v.Format = "atom_10"
+		validateAtom(trimmed, v)
+	default:
+		v.addProblem("Unknown feed type, root element is <%s>", root.Local)
+	}
+
+	return v, nil
+}
+
+// xmlRootElement checks that body is well-formed XML and returns the name of its
+// root element.
+
+func xmlRootElement(body []byte) (*xml.Name, error) {
+
+	dec := xml.NewDecoder(bytes.NewReader(body))
+	dec.Strict = true
+
+	var root *xml.Name
+
+	for {
+
+		tok, err := dec.Token()
+
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		el, ok := tok.(xml.StartElement)
+
+		if ok && root == nil {
+			name := el.Name
+			root = &name
+		}
+	}
+
+	if root == nil {
+		return nil, fmt.Errorf("Missing root element")
+	}
+
+	return root, nil
+}
+
+type rssLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr"`
+}
+
+type rssDocument struct {
+	Version string `xml:"version,attr"`
+	Channel *struct {
+		AtomLinks     []rssLink `xml:"http://www.w3.org/2005/Atom link"`
+		Title         *string   `xml:"title"`
+		Link          *string   `xml:"link"`
+		Description   *string   `xml:"description"`
+		PubDate       string    `xml:"pubDate"`
+		LastBuildDate string    `xml:"lastBuildDate"`
+		Items         []struct {
+			Title       string `xml:"title"`
+			Link        string `xml:"link"`
+			Description string `xml:"description"`
+			PubDate     string `xml:"pubDate"`
+			GUID        *struct {
+				IsPermaLink string `xml:"isPermaLink,attr"`
+				Value       string `xml:",chardata"`
+			} `xml:"guid"`
+			Enclosures []struct {
+				URL    string `xml:"url,attr"`
+				Length string `xml:"length,attr"`
+				Type   string `xml:"type,attr"`
+			} `xml:"enclosure"`
+		} `xml:"item"`
+	} `xml:"channel"`
+}
+
+func validateRSS(body []byte, v *FeedValidation) {
+
+	var doc rssDocument
+
+	err := xml.Unmarshal(body, &doc)
+
+	if err != nil {
+		v.addProblem("Failed to parse RSS document: %v", err)
+		return
+	}
+
+	if doc.Version != "2.0" {
+		v.addProblem("Invalid or missing RSS version '%s'", doc.Version)
+	}
+
+	ch := doc.Channel
+
+	if ch == nil {
+		v.addProblem("Missing <channel> element")
+		return
+	}
+
+	if ch.Title == nil || strings.TrimSpace(*ch.Title) == "" {
+		v.addProblem("Missing channel <title>")
+	}
+
+	if ch.Description == nil {
+		v.addProblem("Missing channel <description>")
+	}
+
+	if ch.Link == nil || strings.TrimSpace(*ch.Link) == "" {
+		v.addProblem("Missing channel <link>")
+	} else {
+		checkAbsoluteURL(v, "channel <link>", *ch.Link)
+	}
+
+	for _, l := range ch.AtomLinks {
+		checkAbsoluteURL(v, "channel <atom:link>", l.Href)
+	}
+
+	checkRSSDate(v, "channel <pubDate>", ch.PubDate)
+	checkRSSDate(v, "channel <lastBuildDate>", ch.LastBuildDate)
+
+	guids := make(map[string]int)
+
+	for idx, item := range ch.Items {
+
+		label := fmt.Sprintf("item %d", idx+1)
+
+		if item.Title == "" && item.Description == "" {
+			v.addProblem("%s must have a <title> or a <description>", label)
+		}
+
+		if item.Link != "" {
+			checkAbsoluteURL(v, label+" <link>", item.Link)
+		}
+
+		checkRSSDate(v, label+" <pubDate>", item.PubDate)
+
+		if item.GUID == nil || strings.TrimSpace(item.GUID.Value) == "" {
+			v.addWarning("%s has no <guid>", label)
+		} else {
+
+			guid := strings.TrimSpace(item.GUID.Value)
+
+			if other, ok := guids[guid]; ok {
+				v.addProblem("%s has the same <guid> as item %d (%s)", label, other, guid)
+			} else {
+				guids[guid] = idx + 1
+			}
+
+			if item.GUID.IsPermaLink != "false" {
+				checkAbsoluteURL(v, label+" <guid> (isPermaLink)", guid)
+			}
+		}
+
+		for _, e := range item.Enclosures {
+
+			checkAbsoluteURL(v, label+" <enclosure>", e.URL)
+
+			if e.Length == "" || e.Type == "" {
+				v.addProblem("%s <enclosure> must have url, length and type attributes", label)
+				continue
+			}
+
+			length, err := strconv.ParseInt(strings.TrimSpace(e.Length), 10, 64)
+
+			if err != nil || length <= 0 {
+				v.addProblem("%s <enclosure> length must be the size of the file in bytes (%s)", label, e.Length)
+			}
+		}
+	}
+}
+
+type atomPerson struct {
+	Name string `xml:"http://www.w3.org/2005/Atom name"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr"`
+}
+
+type atomDocument struct {
+	Id      *string      `xml:"http://www.w3.org/2005/Atom id"`
+	Title   *string      `xml:"http://www.w3.org/2005/Atom title"`
+	Updated *string      `xml:"http://www.w3.org/2005/Atom updated"`
+	Authors []atomPerson `xml:"http://www.w3.org/2005/Atom author"`
+	Links   []atomLink   `xml:"http://www.w3.org/2005/Atom link"`
+	Entries []struct {
+		Id        *string      `xml:"http://www.w3.org/2005/Atom id"`
+		Title     *string      `xml:"http://www.w3.org/2005/Atom title"`
+		Updated   *string      `xml:"http://www.w3.org/2005/Atom updated"`
+		Published string       `xml:"http://www.w3.org/2005/Atom published"`
+		Authors   []atomPerson `xml:"http://www.w3.org/2005/Atom author"`
+		Links     []atomLink   `xml:"http://www.w3.org/2005/Atom link"`
+	} `xml:"http://www.w3.org/2005/Atom entry"`
+}
+
+func validateAtom(body []byte, v *FeedValidation) {
+
+	var doc atomDocument
+
+	err := xml.Unmarshal(body, &doc)
+
+	if err != nil {
+		v.addProblem("Failed to parse Atom document: %v", err)
+		return
+	}
+
+	checkRequired(v, "feed <id>", doc.Id)
+	checkRequired(v, "feed <title>", doc.Title)
+	checkRequired(v, "feed <updated>", doc.Updated)
+
+	if doc.Id != nil && *doc.Id != "" {
+		checkAbsoluteURI(v, "feed <id>", *doc.Id)
+	}
+
+	if doc.Updated != nil {
+		checkRFC3339Date(v, "feed <updated>", *doc.Updated)
+	}
+
+	for _, l := range doc.Links {
+		checkAbsoluteURL(v, "feed <link>", l.Href)
+	}
+
+	feed_has_author := len(doc.Authors) > 0
+
+	ids := make(map[string]int)
+
+	for idx, e := range doc.Entries {
+
+		label := fmt.Sprintf("entry %d", idx+1)
+
+		checkRequired(v, label+" <id>", e.Id)
+		checkRequired(v, label+" <title>", e.Title)
+		checkRequired(v, label+" <updated>", e.Updated)
+
+		if e.Updated != nil {
+			checkRFC3339Date(v, label+" <updated>", *e.Updated)
+		}
+
+		if e.Published != "" {
+			checkRFC3339Date(v, label+" <published>", e.Published)
+		}
+
+		if !feed_has_author && len(e.Authors) == 0 {
+			v.addProblem("%s has no <author> and neither does the feed", label)
+		}
+
+		if e.Id != nil && *e.Id != "" {
+
+			id := strings.TrimSpace(*e.Id)
+
+			if other, ok := ids[id]; ok {
+				v.addProblem("%s has the same <id> as entry %d (%s)", label, other, id)
+			} else {
+				ids[id] = idx + 1
+			}
+
+			checkAbsoluteURI(v, label+" <id>", id)
+		}
+
+		for _, l := range e.Links {
+			checkAbsoluteURL(v, label+" <link>", l.Href)
+		}
+	}
+}
+
+type jsonFeedDocument struct {
+	Version     string  `json:"version"`
+	Title       *string `json:"title"`
+	HomePageURL string  `json:"home_page_url"`
+	FeedURL     string  `json:"feed_url"`
+	Items       *[]struct {
+		Id            interface{} `json:"id"`
+		URL           string      `json:"url"`
+		ExternalURL   string      `json:"external_url"`
+		ContentHTML   *string     `json:"content_html"`
+		ContentText   *string     `json:"content_text"`
+		Image         string      `json:"image"`
+		DatePublished string      `json:"date_published"`
+		DateModified  string      `json:"date_modified"`
+	} `json:"items"`
+}
+
+func validateJSONFeed(body []byte, v *FeedValidation) {
+
+	var doc jsonFeedDocument
+
+	err := json.Unmarshal(body, &doc)
+
+	if err != nil {
+		v.addProblem("Feed is not well-formed JSON: %v", err)
+		return
+	}
+
+	if !strings.HasPrefix(doc.Version, "https://jsonfeed.org/version/") {
+		v.addProblem("Invalid or missing JSON Feed version '%s'", doc.Version)
+	}
+
+	checkRequired(v, "title", doc.Title)
+
+	if doc.HomePageURL != "" {
+		checkAbsoluteURL(v, "home_page_url", doc.HomePageURL)
+	}
+
+	if doc.FeedURL != "" {
+		checkAbsoluteURL(v, "feed_url", doc.FeedURL)
+	}
+
+	if doc.Items == nil {
+		v.addProblem("Missing items")
+		return
+	}
+
+	ids := make(map[string]int)
+
+	for idx, item := range *doc.Items {
+
+		label := fmt.Sprintf("item %d", idx+1)
+
+		id := ""
+
+		switch i := item.Id.(type) {
+		case string:
+			id = i
+		case float64:
+			id = fmt.Sprintf("%v", i)
+		}
+
+		if id == "" {
+			v.addProblem("%s is missing an id", label)
+		} else if other, ok := ids[id]; ok {
+			v.addProblem("%s has the same id as item %d (%s)", label, other, id)
+		} else {
+			ids[id] = idx + 1
+		}
+
+		if item.ContentHTML == nil && item.ContentText == nil {
+			v.addProblem("%s must have content_html or content_text", label)
+		}
+
+		if item.URL != "" {
+			checkAbsoluteURL(v, label+" url", item.URL)
+		}
+
+		if item.ExternalURL != "" {
+			checkAbsoluteURL(v, label+" external_url", item.ExternalURL)
+		}
+
+		if item.Image != "" {
+			checkAbsoluteURL(v, label+" image", item.Image)
+		}
+
+		if item.DatePublished != "" {
+			checkRFC3339Date(v, label+" date_published", item.DatePublished)
+		}
+
+		if item.DateModified != "" {
+			checkRFC3339Date(v, label+" date_modified", item.DateModified)
+		}
+	}
+}
+
+func checkRequired(v *FeedValidation, label string, value *string) {
+
+	if value == nil || strings.TrimSpace(*value) == "" {
+		v.addProblem("Missing %s", label)
+	}
+}
+
+func checkAbsoluteURL(v *FeedValidation, label string, str_url string) {
+
+	u, err := url.Parse(strings.TrimSpace(str_url))
+
+	if err != nil {
+		v.addProblem("%s is not a valid URL (%s)", label, str_url)
+		return
+	}
+
+	if !u.IsAbs() || u.Host == "" {
+		v.addProblem("%s is not an absolute URL (%s)", label, str_url)
+	}
+}
+
+// checkAbsoluteURI is like checkAbsoluteURL but allows URIs without a host (for
+// example tag: or urn: URIs) as used by Atom ids.
+
+func checkAbsoluteURI(v *FeedValidation, label string, str_uri string) {
+
+	u, err := url.Parse(strings.TrimSpace(str_uri))
+
+	if err != nil || !u.IsAbs() {
+		v.addProblem("%s is not an absolute URI (%s)", label, str_uri)
+	}
+}
+
+// RFC 822 dates, as used by RSS, allow a number of variations
+
+var rss_date_formats = []string{
+	time.RFC1123Z,
+	time.RFC1123,
+	"Mon, 2 Jan 2006 15:04:05 -0700",
+	"Mon, 2 Jan 2006 15:04:05 MST",
+	time.RFC822Z,
+	time.RFC822,
+}
+
+func checkRSSDate(v *FeedValidation, label string, dt string) {
+
+	dt = strings.TrimSpace(dt)
+
+	if dt == "" {
+		return
+	}
+
+	for _, layout := range rss_date_formats {
+
+		_, err := time.Parse(layout, dt)
+
+		if err == nil {
+			return
+		}
+	}
+
+	v.addProblem("%s is not a valid RFC 822 date (%s)", label, dt)
+}
+
+func checkRFC3339Date(v *FeedValidation, label string, dt string) {
+
+	_, err := time.Parse(time.RFC3339, strings.TrimSpace(dt))
+
+	if err != nil {
+		v.addProblem("%s is not a valid RFC 3339 date (%s)", label, dt)
+	}
+}
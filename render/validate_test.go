@@ -0,0 +1,278 @@
+package render
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func testRSS(items string) string {
+
+	return `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0">
+  <channel>
+    <title>Example</title>
+    <link>https://example.com/</link>
+    <description>An example</description>
+    <lastBuildDate>Mon, 01 Jan 2018 00:00:00 +0000</lastBuildDate>
+` + items + `
+  </channel>
+</rss>`
+}
+
+func testAtom(entries string) string {
+
+	return `<?xml version="1.0" encoding="UTF-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom">
+  <id>https://example.com/</id>
+  <title>Example</title>
+  <updated>2018-01-01T00:00:00Z</updated>
+  <link href="https://example.com/" rel="alternate"/>
+` + entries + `
+</feed>`
+}
+
+func testJSONFeed(items string) string {
+
+	return `{
+  "version": "https://jsonfeed.org/version/1.1",
+  "title": "Example",
+  "home_page_url": "https://example.com/",
+  "items": [` + items + `]
+}`
+}
+
+func TestValidateFeed(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		feed     string
+		format   string
+		problems []string
+		warnings []string
+	}{
+		{
+			name:   "empty",
+			feed:   " \n",
+			format: "",
+			problems: []string{
+				"Feed is empty",
+			},
+		},
+		{
+			name:   "unknown root element",
+			feed:   `<html><body></body></html>`,
+			format: "",
+			problems: []string{
+				"Unknown feed type, root element is <html>",
+			},
+		},
+		{
+			name: "rss",
+			feed: testRSS(`
+    <item>
+      <title>A</title>
+      <link>https://example.com/a/</link>
+      <guid isPermaLink="true">https://example.com/a/</guid>
+      <pubDate>Mon, 01 Jan 2018 00:00:00 +0000</pubDate>
+      <enclosure url="https://example.com/a/a.mp3" length="1234" type="audio/mpeg"/>
+    </item>`),
+			format: "rss_20",
+		},
+		{
+			name: "rss without a guid",
+			feed: testRSS(`
+    <item>
+      <title>A</title>
+      <link>https://example.com/a/</link>
+    </item>
+    <item>
+      <title>B</title>
+      <link>https://example.com/b/</link>
+    </item>`),
+			format: "rss_20",
+			warnings: []string{
+				"item 1 has no <guid>",
+				"item 2 has no <guid>",
+			},
+		},
+		{
+			name: "rss with the same guid twice",
+			feed: testRSS(`
+    <item>
+      <title>A</title>
+      <guid isPermaLink="false">a</guid>
+    </item>
+    <item>
+      <title>B</title>
+      <guid isPermaLink="false">a</guid>
+    </item>`),
+			format: "rss_20",
+			problems: []string{
+				"item 2 has the same <guid> as item 1 (a)",
+			},
+		},
+		{
+			name: "rss with relative links and bad dates",
+			feed: testRSS(`
+    <item>
+      <title>A</title>
+      <link>/a/</link>
+      <guid>/a/</guid>
+      <pubDate>2018-01-01</pubDate>
+    </item>`),
+			format: "rss_20",
+			problems: []string{
+				"item 1 <link> is not an absolute URL (/a/)",
+				"item 1 <pubDate> is not a valid RFC 822 date (2018-01-01)",
+				"item 1 <guid> (isPermaLink) is not an absolute URL (/a/)",
+			},
+		},
+		{
+			name: "rss enclosures",
+			feed: testRSS(`
+    <item>
+      <title>A</title>
+      <guid isPermaLink="false">a</guid>
+      <enclosure url="https://example.com/a/a.png" length="0" type="image/png"/>
+      <enclosure url="https://example.com/a/b.png" length="big" type="image/png"/>
+      <enclosure url="https://example.com/a/c.png" type="image/png"/>
+    </item>`),
+			format: "rss_20",
+			problems: []string{
+				"item 1 <enclosure> length must be the size of the file in bytes (0)",
+				"item 1 <enclosure> length must be the size of the file in bytes (big)",
+				"item 1 <enclosure> must have url, length and type attributes",
+			},
+		},
+		{
+			name:   "rss without a channel",
+			feed:   `<rss version="2.0"></rss>`,
+			format: "rss_20",
+			problems: []string{
+				"Missing <channel> element",
+			},
+		},
+		{
+			name: "atom",
+			feed: testAtom(`
+  <entry>
+    <id>https://example.com/a/</id>
+    <title>A</title>
+    <updated>2018-01-01T00:00:00Z</updated>
+    <author><name>alice</name></author>
+    <link href="https://example.com/a/"/>
+  </entry>`),
+			format: "atom_10",
+		},
+		{
+			name: "atom problems",
+			feed: testAtom(`
+  <entry>
+    <id>https://example.com/a/</id>
+    <title>A</title>
+    <updated>yesterday</updated>
+    <author><name>alice</name></author>
+  </entry>
+  <entry>
+    <id>https://example.com/a/</id>
+    <title>B</title>
+    <updated>2018-01-01T00:00:00Z</updated>
+  </entry>`),
+			format: "atom_10",
+			problems: []string{
+				"entry 1 <updated> is not a valid RFC 3339 date (yesterday)",
+				"entry 2 has no <author> and neither does the feed",
+				"entry 2 has the same <id> as entry 1 (https://example.com/a/)",
+			},
+		},
+		{
+			name: "json feed",
+			feed: testJSONFeed(`
+    { "id": "https://example.com/a/", "url": "https://example.com/a/", "content_html": "<p>A</p>", "date_published": "2018-01-01T00:00:00Z" },
+    { "id": 2, "content_text": "B" }`),
+			format: "jsonfeed_11",
+		},
+		{
+			name: "json feed problems",
+			feed: testJSONFeed(`
+    { "id": "a", "url": "/a/", "content_html": "<p>A</p>" },
+    { "id": "a", "content_text": "B", "date_published": "2018-01-01" },
+    { "content_text": "C" },
+    { "id": "d" }`),
+			format: "jsonfeed_11",
+			problems: []string{
+				"item 1 url is not an absolute URL (/a/)",
+				"item 2 has the same id as item 1 (a)",
+				"item 2 date_published is not a valid RFC 3339 date (2018-01-01)",
+				"item 3 is missing an id",
+				"item 4 must have content_html or content_text",
+			},
+		},
+		{
+			name:   "json feed without items",
+			feed:   `{ "version": "https://jsonfeed.org/version/1.1", "title": "Example" }`,
+			format: "jsonfeed_11",
+			problems: []string{
+				"Missing items",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+
+		t.Run(tt.name, func(t *testing.T) {
+
+			v, err := ValidateFeed(strings.NewReader(tt.feed))
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if v.Format != tt.format {
+				t.Errorf("Expected format '%s', got '%s'", tt.format, v.Format)
+			}
+
+			problems := tt.problems
+
+			if problems == nil {
+				problems = []string{}
+			}
+
+			if !reflect.DeepEqual(v.Problems, problems) {
+				t.Errorf("Expected problems %q, got %q", problems, v.Problems)
+			}
+
+			warnings := tt.warnings
+
+			if warnings == nil {
+				warnings = []string{}
+			}
+
+			if !reflect.DeepEqual(v.Warnings, warnings) {
+				t.Errorf("Expected warnings %q, got %q", warnings, v.Warnings)
+			}
+
+			if v.Valid() != (len(problems) == 0) {
+				t.Errorf("Expected Valid() to be %t", len(problems) == 0)
+			}
+		})
+	}
+}
+
+func TestValidateFeedNotWellFormed(t *testing.T) {
+
+	v, err := ValidateFeed(strings.NewReader(`<rss version="2.0"><channel>`))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if v.Valid() {
+		t.Fatal("Expected a feed that isn't well-formed XML to be invalid")
+	}
+
+	if !strings.HasPrefix(v.Problems[0], "Feed is not well-formed XML") {
+		t.Errorf("Unexpected problem '%s'", v.Problems[0])
+	}
+}
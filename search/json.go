@@ -0,0 +1,171 @@
+package search
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/whosonfirst/go-whosonfirst-markdown"
+)
+
+// JSONIndexer is an Indexer that keeps documents in memory so that they can be
+// written as a single JSON file, for searching a static site in the browser.
+
+type JSONIndexer struct {
+	// documents are keyed by their permalink or, if they don't have one, the
+	// path to the file they were parsed from
+	docs map[string]*SearchDocument
+	mu   *sync.Mutex
+}
+
+// JSONDocument is the record written for each document by JSONIndexer.
+
+type JSONDocument struct {
+	Id       string   `json:"id"`
+	Title    string   `json:"title"`
+	Category string   `json:"category,omitempty"`
+	Tags     []string `json:"tags,omitempty"`
+	Authors  []string `json:"authors,omitempty"`
+	Date     string   `json:"date,omitempty"`
+	Body     string   `json:"body"`
+}
+
+func NewJSONIndexer() (*JSONIndexer, error) {
+
+	i := JSONIndexer{
+		docs: make(map[string]*SearchDocument),
+		mu:   new(sync.Mutex),
+	}
+
+	return &i, nil
+}
+
+func (i *JSONIndexer) IndexDocument(doc *markdown.Document) (*SearchDocument, error) {
+
+	if doc.Body == nil {
+		return nil, errors.New(fmt.Sprintf("%s was parsed without a body", doc.Path))
+	}
+
+	search_doc, err := NewSearchDocument(doc)
+
+	if err != nil {
+		return nil, err
+	}
+
+	key := search_doc.Id
+
+	if key == "" {
+		key = doc.Path
+	}
+
+	i.mu.Lock()
+	i.docs[key] = search_doc
+	i.mu.Unlock()
+
+	return search_doc, nil
+}
+
+// Query returns the documents (as a []*SearchDocument) whose title, category,
+// tags, authors or body contain every word in q, ignoring case.
+
+func (i *JSONIndexer) Query(q *SearchQuery) (interface{}, error) {
+
+	terms := strings.Fields(strings.ToLower(q.QueryString))
+
+	results := make([]*SearchDocument, 0)
+
+	for _, search_doc := range i.documents() {
+
+		text := strings.ToLower(strings.Join([]string{
+			search_doc.Title,
+			search_doc.Category,
+			strings.Join(search_doc.Tags, " "),
+			strings.Join(search_doc.Authors, " "),
+			strings.Join(search_doc.Body, " "),
+		}, " "))
+
+		match := true
+
+		for _, t := range terms {
+
+			if !strings.Contains(text, t) {
+				match = false
+				break
+			}
+		}
+
+		if match {
+			results = append(results, search_doc)
+		}
+	}
+
+	return results, nil
+}
+
+// Reader returns every document indexed so far as a JSON list of JSONDocument
+// records, sorted by permalink (or path, for documents without a permalink) so
+// that the output only changes when the documents do.
+
+func (i *JSONIndexer) Reader() (io.ReadCloser, error) {
+
+	records := make([]*JSONDocument, 0)
+
+	for _, search_doc := range i.documents() {
+
+		r := JSONDocument{
+			Id:       search_doc.Id,
+			Title:    search_doc.Title,
+			Category: search_doc.Category,
+			Tags:     search_doc.Tags,
+			Authors:  search_doc.Authors,
+			Body:     strings.Join(search_doc.Body, " "),
+		}
+
+		if search_doc.Date != nil {
+			r.Date = search_doc.Date.Format(time.RFC3339)
+		}
+
+		records = append(records, &r)
+	}
+
+	body, err := json.Marshal(records)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return ioutil.NopCloser(bytes.NewReader(body)), nil
+}
+
+func (i *JSONIndexer) Close() error {
+	return nil
+}
+
+func (i *JSONIndexer) documents() []*SearchDocument {
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	keys := make([]string, 0)
+
+	for k, _ := range i.docs {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	docs := make([]*SearchDocument, 0)
+
+	for _, k := range keys {
+		docs = append(docs, i.docs[k])
+	}
+
+	return docs
+}
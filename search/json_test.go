@@ -0,0 +1,179 @@
+package search
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/whosonfirst/go-whosonfirst-markdown"
+	"github.com/whosonfirst/go-whosonfirst-markdown/jekyll"
+)
+
+func testDocument(path string, permalink string, title string, body string) *markdown.Document {
+
+	fm := jekyll.EmptyFrontMatter()
+	fm.Permalink = permalink
+	fm.Title = title
+
+	return &markdown.Document{
+		FrontMatter: fm,
+		Body:        &markdown.Body{Buffer: bytes.NewBufferString(body)},
+		Path:        path,
+	}
+}
+
+func TestJSONIndexer(t *testing.T) {
+
+	tests := []struct {
+		name   string
+		docs   []*markdown.Document
+		query  string
+		ids    []string
+		titles []string
+		found  []string
+	}{
+		{
+			name: "permalinks",
+			docs: []*markdown.Document{
+				testDocument("/blog/b/index.md", "/b/", "Bravo", "Second post"),
+				testDocument("/blog/a/index.md", "/a/", "Alpha", "First post"),
+			},
+			query:  "post",
+			ids:    []string{"/a/", "/b/"},
+			titles: []string{"Alpha", "Bravo"},
+			found:  []string{"Alpha", "Bravo"},
+		},
+		{
+			name: "no permalinks",
+			docs: []*markdown.Document{
+				testDocument("/blog/b/index.md", "", "Bravo", "Second post"),
+				testDocument("/blog/a/index.md", "", "Alpha", "First post"),
+			},
+			query:  "first",
+			ids:    []string{"", ""},
+			titles: []string{"Alpha", "Bravo"},
+			found:  []string{"Alpha"},
+		},
+		{
+			name: "same permalink",
+			docs: []*markdown.Document{
+				testDocument("/blog/a/index.md", "/a/", "Alpha", "First post"),
+				testDocument("/blog/a/index.md", "/a/", "Alpha (updated)", "First post, again"),
+			},
+			query:  "AGAIN first",
+			ids:    []string{"/a/"},
+			titles: []string{"Alpha (updated)"},
+			found:  []string{"Alpha (updated)"},
+		},
+		{
+			name: "same path",
+			docs: []*markdown.Document{
+				testDocument("/blog/a/index.md", "", "Alpha", "First post"),
+				testDocument("/blog/a/index.md", "", "Alpha (updated)", "First post, again"),
+			},
+			query:  "alpha",
+			ids:    []string{""},
+			titles: []string{"Alpha (updated)"},
+			found:  []string{"Alpha (updated)"},
+		},
+	}
+
+	for _, tt := range tests {
+
+		t.Run(tt.name, func(t *testing.T) {
+
+			idx, err := NewJSONIndexer()
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, doc := range tt.docs {
+
+				_, err := idx.IndexDocument(doc)
+
+				if err != nil {
+					t.Fatalf("Failed to index %s, %v", doc.Path, err)
+				}
+			}
+
+			fh, err := idx.Reader()
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			body, err := io.ReadAll(fh)
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var records []*JSONDocument
+
+			err = json.Unmarshal(body, &records)
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ids := make([]string, 0)
+			titles := make([]string, 0)
+
+			for _, r := range records {
+				ids = append(ids, r.Id)
+				titles = append(titles, r.Title)
+			}
+
+			if !reflect.DeepEqual(ids, tt.ids) {
+				t.Errorf("Expected ids %v, got %v", tt.ids, ids)
+			}
+
+			if !reflect.DeepEqual(titles, tt.titles) {
+				t.Errorf("Expected titles %v, got %v", tt.titles, titles)
+			}
+
+			q, err := NewDefaultSearchQuery(tt.query)
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			rsp, err := idx.Query(q)
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			found := make([]string, 0)
+
+			for _, search_doc := range rsp.([]*SearchDocument) {
+				found = append(found, search_doc.Title)
+			}
+
+			if !reflect.DeepEqual(found, tt.found) {
+				t.Errorf("Expected %q to find %v, got %v", tt.query, tt.found, found)
+			}
+		})
+	}
+}
+
+func TestJSONIndexerWithoutBody(t *testing.T) {
+
+	idx, err := NewJSONIndexer()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	doc := testDocument("/blog/a/index.md", "/a/", "Alpha", "")
+	doc.Body = nil
+
+	_, err = idx.IndexDocument(doc)
+
+	if err == nil {
+		t.Fatal("Expected a document without a body to be an error")
+	}
+}
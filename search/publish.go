@@ -0,0 +1,36 @@
+package search
+
+import (
+	"github.com/whosonfirst/go-whosonfirst-markdown"
+	"github.com/whosonfirst/go-whosonfirst-markdown/publish"
+)
+
+// PublishedIndexer wraps another Indexer and only indexes documents that filter
+// says are published. Documents are checked relative to root (see publish.Filter)
+// and excluded documents return a nil *SearchDocument without an error.
+
+type PublishedIndexer struct {
+	Indexer
+	filter *publish.Filter
+	root   string
+}
+
+func NewPublishedIndexer(idx Indexer, filter *publish.Filter, root string) Indexer {
+
+	i := PublishedIndexer{
+		Indexer: idx,
+		filter:  filter,
+		root:    root,
+	}
+
+	return &i
+}
+
+func (i *PublishedIndexer) IndexDocument(doc *markdown.Document) (*SearchDocument, error) {
+
+	if !i.filter.Include(i.root, doc.Path, doc.FrontMatter) {
+		return nil, nil
+	}
+
+	return i.Indexer.IndexDocument(doc)
+}
@@ -0,0 +1,542 @@
+// Package site crawls a directory of Markdown files once and holds all of the
+// documents (and their front matter) so that they can be queried, sorted and
+// grouped without every tool having to walk the tree, and parse every file, for
+// itself.
+package site
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/whosonfirst/go-whosonfirst-crawl"
+	"github.com/whosonfirst/go-whosonfirst-markdown"
+	"github.com/whosonfirst/go-whosonfirst-markdown/jekyll"
+	"github.com/whosonfirst/go-whosonfirst-markdown/parser"
+	"github.com/whosonfirst/go-whosonfirst-markdown/publish"
+)
+
+type SiteOptions struct {
+	// the name of the Markdown files to gather, for example index.md
+	Input string
+	// whether to parse (and keep) the body of each document or just its front matter
+	Body bool
+	// which documents are published, if nil every document is included
+	Filter *publish.Filter
+}
+
+func DefaultSiteOptions() *SiteOptions {
+
+	opts := SiteOptions{
+		Input:  "index.md",
+		Body:   false,
+		Filter: publish.DefaultFilter(),
+	}
+
+	return &opts
+}
+
+// Site is every (published) document in a directory, sorted according to
+// jekyll.ComparePosts.
+
+type Site struct {
+	Root     string
+	docs     []*markdown.Document
+	modtimes map[string]time.Time
+	opts     *SiteOptions
+}
+
+// NewSite crawls root and parses every file named opts.Input. If ctx is cancelled
+// before the crawl is finished ctx.Err() is returned rather than part of the site.
+
+func NewSite(ctx context.Context, root string, opts *SiteOptions) (*Site, error) {
+
+	abs_root, err := filepath.Abs(root)
+
+	if err != nil {
+		return nil, err
+	}
+
+	mu := new(sync.Mutex)
+
+	docs := make([]*markdown.Document, 0)
+	modtimes := make(map[string]time.Time)
+
+	cb := func(path string, info os.FileInfo) error {
+
+		select {
+		case <-ctx.Done():
+			return nil
+		default:
+			// pass
+		}
+
+		if info.IsDir() || filepath.Base(path) != opts.Input {
+			return nil
+		}
+
+		doc, err := ParseDocument(path, opts)
+
+		if err != nil {
+			return fmt.Errorf("Failed to parse %s, %v", path, err)
+		}
+
+		if !opts.Filter.Include(abs_root, doc.Path, doc.FrontMatter) {
+			return nil
+		}
+
+		mu.Lock()
+		docs = append(docs, doc)
+		modtimes[doc.Path] = info.ModTime()
+		mu.Unlock()
+
+		return nil
+	}
+
+	c := crawl.NewCrawler(abs_root)
+	err = c.CrawlWithContext(ctx, cb)
+
+	if err != nil {
+		return nil, err
+	}
+
+	// a cancelled crawl stops early, and quietly, so don't mistake what was
+	// found for the whole site
+
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	markdown.SortDocuments(docs)
+
+	s := Site{
+		Root:     abs_root,
+		docs:     docs,
+		modtimes: modtimes,
+		opts:     opts,
+	}
+
+	return &s, nil
+}
+
+// LoadSite returns the site stored in ctx, see SiteFromContext, or crawls root if
+// there isn't one.
+
+func LoadSite(ctx context.Context, root string, opts *SiteOptions) (*Site, error) {
+
+	s := SiteFromContext(ctx, root, opts)
+
+	if s != nil {
+		return s, nil
+	}
+
+	return NewSite(ctx, root, opts)
+}
+
+// SiteFromContext returns the site stored in ctx under "site" if it was crawled
+// from root with the same input, the same publication filter and, if opts.Body is
+// true, with bodies. This is how a site that has already been crawled (by wof-md
+// build, for example) is shared by every tool. Otherwise it returns nil.
+
+func SiteFromContext(ctx context.Context, root string, opts *SiteOptions) *Site {
+
+	s, ok := ctx.Value("site").(*Site)
+
+	if !ok || s == nil {
+		return nil
+	}
+
+	abs_root, err := filepath.Abs(root)
+
+	if err != nil || abs_root != s.Root {
+		return nil
+	}
+
+	if s.opts.Input != opts.Input || (opts.Body && !s.opts.Body) {
+		return nil
+	}
+
+	if !s.opts.Filter.Equal(opts.Filter) {
+		return nil
+	}
+
+	return s
+}
+
+// ParseDocument parses the file at path. The body is only parsed if opts.Body is
+// true. The document's Path is always absolute.
+
+func ParseDocument(path string, opts *SiteOptions) (*markdown.Document, error) {
+
+	abs_path, err := filepath.Abs(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	parse_opts := parser.DefaultParseOptions()
+	parse_opts.Body = opts.Body
+
+	fm, body, err := parser.ParseFile(abs_path, parse_opts)
+
+	if err != nil {
+		return nil, err
+	}
+
+	doc, err := markdown.NewDocument(fm, body)
+
+	if err != nil {
+		return nil, err
+	}
+
+	doc.Path = abs_path
+	return doc, nil
+}
+
+// Documents returns all the documents in the site. The slice is a copy but the
+// documents themselves are shared.
+
+func (s *Site) Documents() []*markdown.Document {
+
+	docs := make([]*markdown.Document, len(s.docs))
+	copy(docs, s.docs)
+
+	return docs
+}
+
+// Document returns the document at path or nil if it is not part of the site.
+
+func (s *Site) Document(path string) *markdown.Document {
+
+	abs_path, err := filepath.Abs(path)
+
+	if err != nil {
+		return nil
+	}
+
+	for _, d := range s.docs {
+
+		if d.Path == abs_path {
+			return d
+		}
+	}
+
+	return nil
+}
+
+// ModTime returns the modification time of the file for doc, as it was when the
+// site was crawled.
+
+func (s *Site) ModTime(doc *markdown.Document) time.Time {
+	return s.modtimes[doc.Path]
+}
+
+// Filter returns the filter used to decide which documents are part of the site.
+
+func (s *Site) Filter() *publish.Filter {
+	return s.opts.Filter
+}
+
+// Relative returns the path of doc relative to the root of the site.
+
+func (s *Site) Relative(doc *markdown.Document) (string, error) {
+	return filepath.Rel(s.Root, doc.Path)
+}
+
+// Query returns the documents that match all of preds, in the same order as
+// Documents.
+
+func (s *Site) Query(preds ...Predicate) []*markdown.Document {
+
+	docs := make([]*markdown.Document, 0)
+
+	for _, d := range s.docs {
+
+		if And(preds...)(d) {
+			docs = append(docs, d)
+		}
+	}
+
+	return docs
+}
+
+func (s *Site) ByTag(tag string) []*markdown.Document {
+	return s.Query(Tagged(tag))
+}
+
+func (s *Site) ByAuthor(author string) []*markdown.Document {
+	return s.Query(AuthoredBy(author))
+}
+
+func (s *Site) ByCategory(category string) []*markdown.Document {
+	return s.Query(InCategory(category))
+}
+
+func (s *Site) ByDateRange(start time.Time, end time.Time) []*markdown.Document {
+	return s.Query(Between(start, end))
+}
+
+// Group groups the documents in the site by the keys returned by key. See
+// GroupDocuments for details.
+
+func (s *Site) Group(key KeyFunc) (*Groups, error) {
+	return GroupDocuments(s.docs, key)
+}
+
+// Predicate reports whether a document should be included in the results of a
+// query.
+
+type Predicate func(*markdown.Document) bool
+
+// And returns a Predicate that matches documents that match all of preds.
+
+func And(preds ...Predicate) Predicate {
+
+	return func(d *markdown.Document) bool {
+
+		for _, p := range preds {
+
+			if !p(d) {
+				return false
+			}
+		}
+
+		return true
+	}
+}
+
+// Or returns a Predicate that matches documents that match any of preds.
+
+func Or(preds ...Predicate) Predicate {
+
+	return func(d *markdown.Document) bool {
+
+		for _, p := range preds {
+
+			if p(d) {
+				return true
+			}
+		}
+
+		return false
+	}
+}
+
+func Not(pred Predicate) Predicate {
+
+	return func(d *markdown.Document) bool {
+		return !pred(d)
+	}
+}
+
+// Tagged matches documents with tag, ignoring case.
+
+func Tagged(tag string) Predicate {
+	return HasValue("tags", tag)
+}
+
+// AuthoredBy matches documents by author, ignoring case.
+
+func AuthoredBy(author string) Predicate {
+	return HasValue("authors", author)
+}
+
+// InCategory matches documents in category or one of its sub-categories (for
+// example "engineering" matches "engineering/data"), ignoring case.
+
+func InCategory(category string) Predicate {
+
+	category = strings.Trim(strings.ToLower(category), "/")
+
+	return func(d *markdown.Document) bool {
+
+		c := strings.Trim(strings.ToLower(d.FrontMatter.Category), "/")
+		return c == category || strings.HasPrefix(c, category+"/")
+	}
+}
+
+// HasValue matches documents where one of the values for the front matter key
+// (see jekyll.FrontMatter.Values) is value, ignoring case.
+
+func HasValue(key string, value string) Predicate {
+
+	value = strings.TrimSpace(value)
+
+	return func(d *markdown.Document) bool {
+
+		for _, v := range d.FrontMatter.Values(key) {
+
+			if strings.EqualFold(strings.TrimSpace(v), value) {
+				return true
+			}
+		}
+
+		return false
+	}
+}
+
+// Between matches documents dated on or after start and before end. A zero start
+// or end is unbounded. Undated documents never match.
+
+func Between(start time.Time, end time.Time) Predicate {
+
+	return func(d *markdown.Document) bool {
+
+		dt := d.FrontMatter.Date
+
+		if dt == nil {
+			return false
+		}
+
+		if !start.IsZero() && dt.Before(start) {
+			return false
+		}
+
+		if !end.IsZero() && !dt.Before(end) {
+			return false
+		}
+
+		return true
+	}
+}
+
+// KeyFunc returns the keys that a document should be grouped by. A document may
+// belong to zero or more groups.
+
+type KeyFunc func(*markdown.Document) ([]string, error)
+
+// ByValues returns a KeyFunc for the values of the front matter key (see
+// jekyll.FrontMatter.Values), for example "tags" or "authors".
+
+func ByValues(key string) KeyFunc {
+
+	return func(d *markdown.Document) ([]string, error) {
+		return d.FrontMatter.Values(key), nil
+	}
+}
+
+// ByDate returns a KeyFunc for the date of a document formatted using layout, for
+// example "2006" to group documents by year. Undated documents are not grouped.
+
+func ByDate(layout string) KeyFunc {
+
+	return func(d *markdown.Document) ([]string, error) {
+
+		if d.FrontMatter.Date == nil {
+			return nil, nil
+		}
+
+		return []string{d.FrontMatter.Date.Format(layout)}, nil
+	}
+}
+
+// Groups are documents grouped by key. The documents in each group are sorted
+// according to jekyll.ComparePosts.
+
+type Groups struct {
+	groups map[string][]*markdown.Document
+}
+
+// GroupDocuments groups docs by the keys returned by key. Empty keys are ignored
+// as are duplicate keys for the same document. Keys that only differ in case, for
+// example "Go" and "go", are the same group and it is named for whichever of them
+// sorts first.
+
+func GroupDocuments(docs []*markdown.Document, key KeyFunc) (*Groups, error) {
+
+	folded := make(map[string][]*markdown.Document)
+	names := make(map[string]string)
+
+	for _, d := range docs {
+
+		keys, err := key(d)
+
+		if err != nil {
+			return nil, err
+		}
+
+		seen := make(map[string]bool)
+
+		for _, k := range keys {
+
+			f := strings.ToLower(k)
+
+			if k == "" || seen[f] {
+				continue
+			}
+
+			seen[f] = true
+			folded[f] = append(folded[f], d)
+
+			name, ok := names[f]
+
+			if !ok || k < name {
+				names[f] = k
+			}
+		}
+	}
+
+	groups := make(map[string][]*markdown.Document)
+
+	for f, group := range folded {
+		markdown.SortDocuments(group)
+		groups[names[f]] = group
+	}
+
+	g := Groups{
+		groups: groups,
+	}
+
+	return &g, nil
+}
+
+// Keys returns the keys for all the groups, sorted alphabetically.
+
+func (g *Groups) Keys() []string {
+
+	keys := make([]string, 0)
+
+	for k, _ := range g.groups {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+	return keys
+}
+
+// Documents returns the documents for key or nil if there is no such group.
+
+func (g *Groups) Documents(key string) []*markdown.Document {
+	return g.groups[key]
+}
+
+// FrontMatter returns the front matter for each group, keyed by the group's key.
+
+func (g *Groups) FrontMatter() map[string][]*jekyll.FrontMatter {
+
+	lookup := make(map[string][]*jekyll.FrontMatter)
+
+	for k, docs := range g.groups {
+		lookup[k] = FrontMatter(docs)
+	}
+
+	return lookup
+}
+
+// FrontMatter returns the front matter for docs, in the same order.
+
+func FrontMatter(docs []*markdown.Document) []*jekyll.FrontMatter {
+
+	fm := make([]*jekyll.FrontMatter, len(docs))
+
+	for i, d := range docs {
+		fm[i] = d.FrontMatter
+	}
+
+	return fm
+}
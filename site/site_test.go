@@ -0,0 +1,100 @@
+package site
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/whosonfirst/go-whosonfirst-markdown"
+	"github.com/whosonfirst/go-whosonfirst-markdown/jekyll"
+)
+
+func TestNewSite(t *testing.T) {
+
+	root := t.TempDir()
+
+	for _, name := range []string{"a", "b", "c"} {
+
+		dir := filepath.Join(root, name)
+
+		err := os.MkdirAll(dir, 0755)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		body := "---\ntitle: " + name + "\npermalink: /" + name + "/\n---\n" + name + "\n"
+
+		err = os.WriteFile(filepath.Join(dir, "index.md"), []byte(body), 0644)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s, err := NewSite(context.Background(), root, DefaultSiteOptions())
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(s.Documents()) != 3 {
+		t.Errorf("Expected 3 documents, got %d", len(s.Documents()))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	s, err = NewSite(ctx, root, DefaultSiteOptions())
+
+	if err == nil {
+		t.Errorf("Expected a cancelled crawl to be an error, got a site with %d documents", len(s.Documents()))
+	}
+}
+
+func TestGroupDocuments(t *testing.T) {
+
+	tags := [][]string{
+		[]string{"Go", "go"},
+		[]string{"go", "Python"},
+		[]string{"golang"},
+	}
+
+	docs := make([]*markdown.Document, len(tags))
+
+	for i, tt := range tags {
+
+		fm := jekyll.EmptyFrontMatter()
+		fm.Tags = tt
+
+		doc, err := markdown.NewDocument(fm, nil)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		docs[i] = doc
+	}
+
+	key := func(d *markdown.Document) ([]string, error) {
+		return d.FrontMatter.Tags, nil
+	}
+
+	groups, err := GroupDocuments(docs, key)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"Go", "Python", "golang"}
+
+	if !reflect.DeepEqual(groups.Keys(), expected) {
+		t.Errorf("Expected groups %v, got %v", expected, groups.Keys())
+	}
+
+	if len(groups.Documents("Go")) != 2 {
+		t.Errorf("Expected 2 documents for Go, got %d", len(groups.Documents("Go")))
+	}
+}
@@ -0,0 +1,271 @@
+package taxonomy
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/whosonfirst/go-whosonfirst-markdown/uri"
+)
+
+// Term is the canonical name for a tag, author, category (and so on) along with
+// any aliases it is known by, an optional description and an optional slug which
+// overrides the default (pruned) path for the term.
+
+type Term struct {
+	Name        string   `json:"name"`
+	Aliases     []string `json:"aliases,omitempty"`
+	Description string   `json:"description,omitempty"`
+	Slug        string   `json:"slug,omitempty"`
+}
+
+type Taxonomy struct {
+	Name  string
+	terms map[string]*Term
+}
+
+type Taxonomies struct {
+	taxonomies map[string]*Taxonomy
+}
+
+func NewTaxonomiesFromFile(path string) (*Taxonomies, error) {
+
+	abs_path, err := filepath.Abs(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	fh, err := os.Open(abs_path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer fh.Close()
+
+	t, err := NewTaxonomiesFromReader(fh)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse %s, %v", abs_path, err)
+	}
+
+	return t, nil
+}
+
+// NewTaxonomiesFromReader reads taxonomies from JSON keyed by the name of the
+// taxonomy (authors, category, tags or any other front matter key) each of which
+// is a list of terms, for example:
+//
+//	{
+//		"tags": [
+//			{ "name": "Go", "aliases": [ "golang", "go-lang" ], "description": "Posts about Go", "slug": "go" }
+//		]
+//	}
+
+func NewTaxonomiesFromReader(fh io.Reader) (*Taxonomies, error) {
+
+	var config map[string][]*Term
+
+	err := json.NewDecoder(fh).Decode(&config)
+
+	if err != nil {
+		return nil, err
+	}
+
+	taxonomies := make(map[string]*Taxonomy)
+
+	for name, terms := range config {
+
+		t, err := NewTaxonomy(name, terms)
+
+		if err != nil {
+			return nil, err
+		}
+
+		taxonomies[name] = t
+	}
+
+	ts := Taxonomies{
+		taxonomies: taxonomies,
+	}
+
+	return &ts, nil
+}
+
+// NewTaxonomy returns a new Taxonomy for terms. It is an error for a name or an
+// alias to refer to more than one term or for more than one term to have the
+// same slug.
+
+func NewTaxonomy(name string, terms []*Term) (*Taxonomy, error) {
+
+	lookup := make(map[string]*Term)
+	slugs := make(map[string]*Term)
+
+	for _, t := range terms {
+
+		if strings.TrimSpace(t.Name) == "" {
+			return nil, errors.New(fmt.Sprintf("Term in %s taxonomy is missing a name", name))
+		}
+
+		candidates := append([]string{t.Name}, t.Aliases...)
+
+		for _, c := range candidates {
+
+			k := normalize(c)
+
+			other, ok := lookup[k]
+
+			if ok && other != t {
+				return nil, errors.New(fmt.Sprintf("'%s' refers to both '%s' and '%s' in %s taxonomy", c, other.Name, t.Name, name))
+			}
+
+			lookup[k] = t
+		}
+
+		if t.Slug != "" {
+
+			other, ok := slugs[t.Slug]
+
+			if ok {
+				return nil, errors.New(fmt.Sprintf("'%s' and '%s' have the same slug (%s) in %s taxonomy", other.Name, t.Name, t.Slug, name))
+			}
+
+			slugs[t.Slug] = t
+		}
+	}
+
+	tx := Taxonomy{
+		Name:  name,
+		terms: lookup,
+	}
+
+	return &tx, nil
+}
+
+// Taxonomy returns the taxonomy called name or nil if it is not defined. It is
+// safe to call Taxonomy on a nil *Taxonomies.
+
+func (ts *Taxonomies) Taxonomy(name string) *Taxonomy {
+
+	if ts == nil {
+		return nil
+	}
+
+	return ts.taxonomies[name]
+}
+
+// Term returns the term whose name or alias matches raw (ignoring case) or nil
+// if there is no match. It is safe to call Term, and the other Taxonomy methods,
+// on a nil *Taxonomy.
+
+func (t *Taxonomy) Term(raw string) *Term {
+
+	if t == nil {
+		return nil
+	}
+
+	return t.terms[normalize(raw)]
+}
+
+// Canonical returns the canonical name for raw or raw if it is not a known term
+// or alias.
+
+func (t *Taxonomy) Canonical(raw string) string {
+
+	term := t.Term(raw)
+
+	if term == nil {
+		return raw
+	}
+
+	return term.Name
+}
+
+// Description returns the description for raw or "" if it is not a known term.
+
+func (t *Taxonomy) Description(raw string) string {
+
+	term := t.Term(raw)
+
+	if term == nil {
+		return ""
+	}
+
+	return term.Description
+}
+
+// Slug returns the slug for raw if one is defined or raw pruned using
+// uri.PruneString.
+
+func (t *Taxonomy) Slug(raw string) (string, error) {
+
+	term := t.Term(raw)
+
+	if term != nil && term.Slug != "" {
+		return term.Slug, nil
+	}
+
+	return uri.PruneString(raw)
+}
+
+// SlugPath is like Slug but raw is treated as a hierarchical value, for example
+// "engineering/data", and pruned using uri.PrunePath.
+
+func (t *Taxonomy) SlugPath(raw string) (string, error) {
+
+	term := t.Term(raw)
+
+	if term != nil && term.Slug != "" {
+		return term.Slug, nil
+	}
+
+	return uri.PrunePath(raw)
+}
+
+// CheckSlugs returns an error if two or more different keys map to the same
+// slug, for example "C++" and "C" both of which are pruned to "c". Keys that only
+// differ in case, for example "Go" and "go", are the same key (they are listed on
+// the same index page) and are not a collision.
+
+func CheckSlugs(keys []string, slug func(string) (string, error)) error {
+
+	seen := make(map[string]string)
+
+	sorted := make([]string, len(keys))
+	copy(sorted, keys)
+
+	sort.Strings(sorted)
+
+	for _, k := range sorted {
+
+		s, err := slug(k)
+
+		if err != nil {
+			return err
+		}
+
+		if s == "" {
+			continue
+		}
+
+		other, ok := seen[s]
+
+		if ok && !strings.EqualFold(other, k) {
+			return errors.New(fmt.Sprintf("Slug collision: '%s' and '%s' both map to '%s'. Use a taxonomy to alias them or give them different slugs", other, k, s))
+		}
+
+		seen[s] = k
+	}
+
+	return nil
+}
+
+func normalize(raw string) string {
+	return strings.ToLower(strings.TrimSpace(raw))
+}
@@ -0,0 +1,141 @@
+package taxonomy
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/whosonfirst/go-whosonfirst-markdown/uri"
+)
+
+func TestCheckSlugs(t *testing.T) {
+
+	tags, err := NewTaxonomy("tags", []*Term{
+		&Term{Name: "C++", Slug: "cpp"},
+		&Term{Name: "Go", Aliases: []string{"golang"}},
+		&Term{Name: "Data", Slug: "datascience"},
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var nil_taxonomy *Taxonomy
+
+	failing := func(raw string) (string, error) {
+		return "", errors.New("Failed to prune")
+	}
+
+	tests := []struct {
+		name     string
+		keys     []string
+		slug     func(string) (string, error)
+		expected string
+	}{
+		{
+			name: "no keys",
+			keys: []string{},
+			slug: uri.PruneString,
+		},
+		{
+			name: "different slugs",
+			keys: []string{"Go", "Python", "Data"},
+			slug: uri.PruneString,
+		},
+		{
+			name: "same key twice",
+			keys: []string{"Go", "Go"},
+			slug: uri.PruneString,
+		},
+		{
+			name:     "pruned to the same slug",
+			keys:     []string{"C++", "C"},
+			slug:     uri.PruneString,
+			expected: "Slug collision: 'C' and 'C++' both map to 'c'. Use a taxonomy to alias them or give them different slugs",
+		},
+		{
+			name: "different case",
+			keys: []string{"go", "Go", "GO"},
+			slug: uri.PruneString,
+		},
+		{
+			name:     "different names pruned to the same slug",
+			keys:     []string{"golang", "go-lang"},
+			slug:     uri.PruneString,
+			expected: "Slug collision: 'go-lang' and 'golang' both map to 'golang'. Use a taxonomy to alias them or give them different slugs",
+		},
+		{
+			name: "empty slugs are ignored",
+			keys: []string{"!!", "??"},
+			slug: uri.PruneString,
+		},
+		{
+			name: "taxonomy slug",
+			keys: []string{"C++", "C"},
+			slug: tags.Slug,
+		},
+		{
+			name: "alias without a slug",
+			keys: []string{"Go", "GO"},
+			slug: tags.Slug,
+		},
+		{
+			name:     "pruned to the slug of a term",
+			keys:     []string{"Go", "G.O."},
+			slug:     tags.Slug,
+			expected: "Slug collision: 'G.O.' and 'Go' both map to 'go'. Use a taxonomy to alias them or give them different slugs",
+		},
+		{
+			name:     "pruned to a taxonomy slug",
+			keys:     []string{"Data", "Data Science"},
+			slug:     tags.Slug,
+			expected: "Slug collision: 'Data' and 'Data Science' both map to 'datascience'. Use a taxonomy to alias them or give them different slugs",
+		},
+		{
+			name: "nil taxonomy",
+			keys: []string{"C++", "Go"},
+			slug: nil_taxonomy.Slug,
+		},
+		{
+			name: "paths",
+			keys: []string{"Engineering/Data", "Engineering", "Data"},
+			slug: nil_taxonomy.SlugPath,
+		},
+		{
+			name:     "paths pruned to the same slug",
+			keys:     []string{"Engineering/Big Data", "engineering/bigdata"},
+			slug:     nil_taxonomy.SlugPath,
+			expected: "Slug collision: 'Engineering/Big Data' and 'engineering/bigdata' both map to 'engineering/bigdata'. Use a taxonomy to alias them or give them different slugs",
+		},
+		{
+			name:     "slug error",
+			keys:     []string{"Go"},
+			slug:     failing,
+			expected: "Failed to prune",
+		},
+	}
+
+	for _, tt := range tests {
+
+		t.Run(tt.name, func(t *testing.T) {
+
+			err := CheckSlugs(tt.keys, tt.slug)
+
+			if tt.expected == "" {
+
+				if err != nil {
+					t.Errorf("Unexpected error, %v", err)
+				}
+
+				return
+			}
+
+			if err == nil {
+				t.Fatalf("Expected an error '%s'", tt.expected)
+			}
+
+			if err.Error() != tt.expected {
+				t.Errorf("Expected error '%s', got '%s'", tt.expected, err.Error())
+			}
+		})
+	}
+}
@@ -3,6 +3,7 @@ package uri
 import (
 	"golang.org/x/text/transform"
 	"golang.org/x/text/unicode/norm"
+	"path"
 	"strings"
 )
 
@@ -51,3 +52,50 @@ func PruneStringWithAllowed(raw string, allowed [][]int) (string, error) {
 
 	return clean, err
 }
+
+// PrunePath prunes each of the "/" separated components of raw, for example
+// "Engineering/Big Data" becomes "engineering/bigdata". Empty components are
+// removed.
+
+func PrunePath(raw string) (string, error) {
+
+	parts := make([]string, 0)
+
+	for _, p := range strings.Split(raw, "/") {
+
+		clean, err := PruneString(p)
+
+		if err != nil {
+			return "", err
+		}
+
+		if clean != "" {
+			parts = append(parts, clean)
+		}
+	}
+
+	return path.Join(parts...), nil
+}
+
+// PathAncestors returns raw and each of its "/" separated parents, for example
+// "engineering/data" returns "engineering" and "engineering/data".
+
+func PathAncestors(raw string) []string {
+
+	ancestors := make([]string, 0)
+	parts := make([]string, 0)
+
+	for _, p := range strings.Split(raw, "/") {
+
+		p = strings.TrimSpace(p)
+
+		if p == "" {
+			continue
+		}
+
+		parts = append(parts, p)
+		ancestors = append(ancestors, strings.Join(parts, "/"))
+	}
+
+	return ancestors
+}
@@ -0,0 +1,264 @@
+// Package watch polls one or more files or directories for changes. It doesn't
+// depend on inotify (or any other platform specific notifications) so it works
+// everywhere, including network and container file systems, at the cost of
+// walking the tree every Interval.
+package watch
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+type Op string
+
+const (
+	Created  Op = "created"
+	Modified Op = "modified"
+	Removed  Op = "removed"
+)
+
+type Change struct {
+	Path string
+	Op   Op
+}
+
+func (c *Change) String() string {
+	return fmt.Sprintf("%s %s", c.Path, c.Op)
+}
+
+type WatcherOptions struct {
+	// how often to check for changes
+	Interval time.Duration
+	// how long to wait after the most recent change before reporting changes,
+	// so that saving several files at once results in a single rebuild
+	Debounce time.Duration
+}
+
+func DefaultWatcherOptions() *WatcherOptions {
+
+	opts := WatcherOptions{
+		Interval: 500 * time.Millisecond,
+		Debounce: 250 * time.Millisecond,
+	}
+
+	return &opts
+}
+
+type fileState struct {
+	modtime time.Time
+	size    int64
+}
+
+type Watcher struct {
+	paths []string
+	opts  *WatcherOptions
+	state map[string]fileState
+}
+
+// NewWatcher returns a new Watcher for paths, which may be files or directories.
+// Directories are watched recursively. Changes are relative to the state of paths
+// when NewWatcher is called.
+
+func NewWatcher(paths []string, opts *WatcherOptions) (*Watcher, error) {
+
+	abs_paths := make([]string, 0)
+
+	for _, p := range paths {
+
+		if p == "" {
+			continue
+		}
+
+		abs_path, err := filepath.Abs(p)
+
+		if err != nil {
+			return nil, err
+		}
+
+		abs_paths = append(abs_paths, abs_path)
+	}
+
+	w := Watcher{
+		paths: abs_paths,
+		opts:  opts,
+	}
+
+	state, err := w.snapshot()
+
+	if err != nil {
+		return nil, err
+	}
+
+	w.state = state
+	return &w, nil
+}
+
+// Changes blocks until one or more files have changed, and then until nothing
+// else has changed for opts.Debounce, and returns the changes sorted by path. It
+// returns nil if ctx is cancelled.
+
+func (w *Watcher) Changes(ctx context.Context) ([]*Change, error) {
+
+	ticker := time.NewTicker(w.opts.Interval)
+	defer ticker.Stop()
+
+	pending := make(map[string]*Change)
+	var last time.Time
+
+	for {
+
+		select {
+		case <-ctx.Done():
+			return nil, nil
+		case <-ticker.C:
+			// pass
+		}
+
+		state, err := w.snapshot()
+
+		if err != nil {
+			return nil, err
+		}
+
+		changes := diff(w.state, state)
+		w.state = state
+
+		for _, c := range changes {
+
+			prev, ok := pending[c.Path]
+
+			// created then modified is still created, created then
+			// removed is nothing at all
+
+			if ok && prev.Op == Created {
+
+				if c.Op == Removed {
+					delete(pending, c.Path)
+				}
+
+				continue
+			}
+
+			pending[c.Path] = c
+		}
+
+		if len(changes) > 0 {
+			last = time.Now()
+			continue
+		}
+
+		if len(pending) > 0 && time.Since(last) >= w.opts.Debounce {
+			break
+		}
+	}
+
+	changes := make([]*Change, 0)
+
+	for _, c := range pending {
+		changes = append(changes, c)
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Path < changes[j].Path
+	})
+
+	return changes, nil
+}
+
+// Watch calls fn with each set of changes until ctx is cancelled. Errors returned
+// by fn are logged rather than stopping the watcher, so that (for example) a typo
+// in a template can be fixed without restarting.
+
+func (w *Watcher) Watch(ctx context.Context, fn func(context.Context, []*Change) error) error {
+
+	for {
+
+		changes, err := w.Changes(ctx)
+
+		if err != nil {
+			return err
+		}
+
+		if changes == nil {
+			return nil
+		}
+
+		err = fn(ctx, changes)
+
+		if err != nil {
+			log.Println(err)
+		}
+	}
+}
+
+func (w *Watcher) snapshot() (map[string]fileState, error) {
+
+	state := make(map[string]fileState)
+
+	for _, root := range w.paths {
+
+		err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+
+			// files may be removed while we're walking the tree and
+			// the root itself may not exist (yet)
+
+			if os.IsNotExist(err) {
+				return nil
+			}
+
+			if err != nil {
+				return err
+			}
+
+			if info.IsDir() {
+				return nil
+			}
+
+			state[path] = fileState{
+				modtime: info.ModTime(),
+				size:    info.Size(),
+			}
+
+			return nil
+		})
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return state, nil
+}
+
+func diff(before map[string]fileState, after map[string]fileState) []*Change {
+
+	changes := make([]*Change, 0)
+
+	for path, a := range after {
+
+		b, ok := before[path]
+
+		if !ok {
+			changes = append(changes, &Change{Path: path, Op: Created})
+			continue
+		}
+
+		if !a.modtime.Equal(b.modtime) || a.size != b.size {
+			changes = append(changes, &Change{Path: path, Op: Modified})
+		}
+	}
+
+	for path, _ := range before {
+
+		if _, ok := after[path]; !ok {
+			changes = append(changes, &Change{Path: path, Op: Removed})
+		}
+	}
+
+	return changes
+}
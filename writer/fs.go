@@ -5,6 +5,7 @@ import (
 	_ "log"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/facebookgo/atomicfile"	
 )
@@ -62,3 +63,31 @@ func (w *FSWriter) Write(rel_path string, fh io.ReadCloser) error {
 
 	return nil
 }
+
+// Remove removes rel_path, and any of its parent directories that are left
+// empty. It is not an error if rel_path does not exist.
+
+func (w *FSWriter) Remove(rel_path string) error {
+
+	out_path := filepath.Join(w.root, rel_path)
+	err := os.Remove(out_path)
+
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	for dir := filepath.Dir(out_path); dir != w.root && strings.HasPrefix(dir, w.root); dir = filepath.Dir(dir) {
+
+		// this fails, and we stop, as soon as a directory isn't empty
+
+		if os.Remove(dir) != nil {
+			break
+		}
+	}
+
+	return nil
+}
+
+func (w *FSWriter) String() string {
+	return "fs=" + w.root
+}
@@ -2,6 +2,7 @@ package writer
 
 import (
 	"bytes"
+	"fmt"
 	"io"
 	"io/ioutil"
 	_ "log"
@@ -51,3 +52,29 @@ func (w *MultiWriter) Write(path string, fh io.ReadCloser) error {
 
 	return nil
 }
+
+// Remove removes path from each of the writers that implement Remover.
+
+func (w *MultiWriter) Remove(path string) error {
+
+	for _, wr := range w.writers {
+
+		r, ok := wr.(Remover)
+
+		if !ok {
+			continue
+		}
+
+		err := r.Remove(path)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (w *MultiWriter) String() string {
+	return fmt.Sprintf("%v", w.writers)
+}
@@ -4,18 +4,35 @@ import (
 	"io"
 	_ "log"
 	"os"
+	"sync"
 )
 
+// StdoutWriter may be called concurrently, writes are serialized so that
+// documents aren't interleaved.
+
 type StdoutWriter struct {
 	Writer
+	mu *sync.Mutex
 }
 
 func NewStdoutWriter() (Writer, error) {
-	w := StdoutWriter{}
+
+	w := StdoutWriter{
+		mu: new(sync.Mutex),
+	}
+
 	return &w, nil
 }
 
 func (w *StdoutWriter) Write(path string, fh io.ReadCloser) error {
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
 	_, err := io.Copy(os.Stdout, fh)
 	return err
 }
+
+func (w *StdoutWriter) String() string {
+	return "stdout"
+}
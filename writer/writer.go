@@ -7,3 +7,10 @@ import (
 type Writer interface {
 	Write(string, io.ReadCloser) error
 }
+
+// Remover is implemented by writers that can remove things they have previously
+// written, for example when the source for a page has been deleted.
+
+type Remover interface {
+	Remove(string) error
+}